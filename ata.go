@@ -1,5 +1,104 @@
 package udisks
 
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// SmartUnit is the unit of SmartAttribute.Pretty
+type SmartUnit int32
+
+const (
+	SmartUnitUnknown SmartUnit = iota
+	SmartUnitNone
+	SmartUnitMilliseconds
+	SmartUnitSectors
+	SmartUnitMillikelvin
+	// SmartUnitSmallPercent is a percentage of a small quantity such as the
+	// ratio of reallocated sectors, shown with three decimals
+	SmartUnitSmallPercent
+	SmartUnitPercent
+	// SmartUnitMegabytes counts 10^6 bytes, e.g. the total host writes
+	SmartUnitMegabytes
+)
+
+// Flags of SmartAttribute
+const (
+	SmartFlagPrefailure uint16 = 1 << 0
+	SmartFlagOnline     uint16 = 1 << 1
+)
+
+// SmartAttribute is an entry of the ATA SMART attribute table. Value, Worst and
+// Threshold are the normalized values, -1 when not available.
+type SmartAttribute struct {
+	ID        uint8
+	Name      string
+	Flags     uint16
+	Value     int32
+	Worst     int32
+	Threshold int32
+	// Pretty is the raw value decoded by libatasmart, in PrettyUnit
+	Pretty     int64
+	PrettyUnit SmartUnit
+}
+
+// PreFail reports whether a failing attribute predicts an imminent failure.
+// Attributes without the flag are old-age attributes.
+func (a SmartAttribute) PreFail() bool {
+	return a.Flags&SmartFlagPrefailure != 0
+}
+
+func (a SmartAttribute) OldAge() bool {
+	return !a.PreFail()
+}
+
+// Failing reports whether the normalized value is at or below the threshold
+func (a SmartAttribute) Failing() bool {
+	return a.Value >= 0 && a.Threshold > 0 && a.Value <= a.Threshold
+}
+
+// Duration returns Pretty as a duration for attributes in milliseconds
+func (a SmartAttribute) Duration() time.Duration {
+	if a.PrettyUnit != SmartUnitMilliseconds {
+		return 0
+	}
+	return time.Duration(a.Pretty) * time.Millisecond
+}
+
+// Celsius returns Pretty in degrees Celsius for attributes in millikelvin
+func (a SmartAttribute) Celsius() float64 {
+	if a.PrettyUnit != SmartUnitMillikelvin {
+		return 0
+	}
+	return float64(a.Pretty)/1000 - 273.15
+}
+
+// PrettyString formats Pretty with its unit, e.g. "12 sectors", "35.0 C" or
+// "98%"
+func (a SmartAttribute) PrettyString() string {
+	switch a.PrettyUnit {
+	case SmartUnitNone:
+		return fmt.Sprint(a.Pretty)
+	case SmartUnitMilliseconds:
+		return a.Duration().String()
+	case SmartUnitSectors:
+		return fmt.Sprintf("%d sectors", a.Pretty)
+	case SmartUnitMillikelvin:
+		return fmt.Sprintf("%.1f C", a.Celsius())
+	case SmartUnitSmallPercent:
+		return fmt.Sprintf("%.3f%%", float64(a.Pretty))
+	case SmartUnitPercent:
+		return fmt.Sprintf("%d%%", a.Pretty)
+	case SmartUnitMegabytes:
+		return fmt.Sprintf("%d MB", a.Pretty)
+	}
+	return "unknown"
+}
+
 type Ata struct {
 	SmartSupported                    bool
 	SmartEnabled                      bool
@@ -27,3 +126,144 @@ type Ata struct {
 	SecurityEnhancedEraseUnitMinutes  int32
 	SecurityFrozen                    bool
 }
+
+// SecurityEraseDuration returns the time the drive estimates for a security
+// erase, 0 if it is not supported
+func (a *Ata) SecurityEraseDuration(enhanced bool) time.Duration {
+	minutes := a.SecurityEraseUnitMinutes
+	if enhanced {
+		minutes = a.SecurityEnhancedEraseUnitMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// DriveInUseError is returned by AtaSecurityErase when block devices of the
+// drive are mounted or unlocked. It matches ErrDeviceBusy with errors.Is.
+type DriveInUseError struct {
+	Devices []string
+}
+
+func (e *DriveInUseError) Error() string {
+	return "drive is in use by " + strings.Join(e.Devices, ", ")
+}
+
+func (e *DriveInUseError) Is(target error) bool {
+	return target == ErrDeviceBusy
+}
+
+func buildAta(props map[string]dbus.Variant) *Ata {
+	ata := &Ata{}
+	prop(props, "SecurityFrozen", &ata.SecurityFrozen)
+	prop(props, "SmartSupported", &ata.SmartSupported)
+	prop(props, "SmartEnabled", &ata.SmartEnabled)
+	prop(props, "SmartFailing", &ata.SmartFailing)
+	prop(props, "PmSupported", &ata.PmSupported)
+	prop(props, "PmEnabled", &ata.PmEnabled)
+	prop(props, "ApmSupported", &ata.ApmSupported)
+	prop(props, "ApmEnabled", &ata.ApmEnabled)
+	prop(props, "AamSupported", &ata.AamSupported)
+	prop(props, "AamEnabled", &ata.AamEnabled)
+	prop(props, "WriteCacheSupported", &ata.WriteCacheSupported)
+	prop(props, "WriteCacheEnabled", &ata.WriteCacheEnabled)
+	prop(props, "ReadLookaheadSupported", &ata.ReadLookaheadSupported)
+	prop(props, "ReadLookaheadEnabled", &ata.ReadLookaheadEnabled)
+	prop(props, "SmartUpdated", &ata.SmartUpdated)
+	prop(props, "SmartPowerOnSeconds", &ata.SmartPowerOnSeconds)
+	prop(props, "SmartNumAttributesFailedInThePast", &ata.SmartNumAttributesFailedInThePast)
+	prop(props, "SmartSelftestPercentRemaining", &ata.SmartSelftestPercentRemaining)
+	prop(props, "AamVendorRecommendedValue", &ata.AamVendorRecommendedValue)
+	prop(props, "SecurityEraseUnitMinutes", &ata.SecurityEraseUnitMinutes)
+	prop(props, "SecurityEnhancedEraseUnitMinutes", &ata.SecurityEnhancedEraseUnitMinutes)
+	prop(props, "SmartTemperature", &ata.SmartTemperature)
+	prop(props, "SmartNumAttributesFailing", &ata.SmartNumAttributesFailing)
+	prop(props, "SmartSelftestStatus", &ata.SmartSelftestStatus)
+	prop(props, "SmartNumBadSectors", &ata.SmartNumBadSectors)
+	return ata
+}
+
+// AtaSmartAttributes returns the SMART attribute table of an ATA drive, e.g. to
+// track attribute 5 (Reallocated_Sector_Ct) and 197 (Current_Pending_Sector)
+func (c *Client) AtaSmartAttributes(d *Drive) ([]SmartAttribute, error) {
+	return c.AtaSmartAttributesContext(context.Background(), d)
+}
+
+func (c *Client) AtaSmartAttributesContext(ctx context.Context, d *Drive) ([]SmartAttribute, error) {
+	if d.Ata == nil || !d.Ata.SmartSupported {
+		return nil, ErrSmartNotSupported
+	}
+	var reply []struct {
+		ID         uint8
+		Name       string
+		Flags      uint16
+		Value      int32
+		Worst      int32
+		Threshold  int32
+		Pretty     int64
+		PrettyUnit int32
+		Expansion  map[string]dbus.Variant
+	}
+	err := c.call(ctx, dbus.ObjectPath(d.Path), "org.freedesktop.UDisks2.Drive.Ata.SmartGetAttributes", map[string]interface{}{}).Store(&reply)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]SmartAttribute, 0, len(reply))
+	for _, r := range reply {
+		attrs = append(attrs, SmartAttribute{
+			ID:         r.ID,
+			Name:       r.Name,
+			Flags:      r.Flags,
+			Value:      r.Value,
+			Worst:      r.Worst,
+			Threshold:  r.Threshold,
+			Pretty:     r.Pretty,
+			PrettyUnit: SmartUnit(r.PrettyUnit),
+		})
+	}
+	return attrs, nil
+}
+
+// SecurityEraseOptions are the options of AtaSecurityErase
+type SecurityEraseOptions struct {
+	// Started is called with the expected duration of the erase, from
+	// Ata.SecurityEraseDuration, right before the erase starts
+	Started func(expected time.Duration)
+}
+
+// AtaSecurityErase erases all data on the ATA drive with the SECURITY ERASE UNIT
+// command and blocks until it is done, which takes about
+// d.Ata.SecurityEraseDuration(enhanced). ErrSecurityFrozen is returned if the
+// drive is frozen and a *DriveInUseError if its block devices are mounted or
+// unlocked. The default timeout of the client does not apply to the erase.
+func (c *Client) AtaSecurityErase(d *Drive, enhanced bool, opts SecurityEraseOptions) error {
+	return c.AtaSecurityEraseContext(context.Background(), d, enhanced, opts)
+}
+
+func (c *Client) AtaSecurityEraseContext(ctx context.Context, d *Drive, enhanced bool, opts SecurityEraseOptions) error {
+	if d.Ata == nil || d.Ata.SecurityEraseDuration(enhanced) == 0 {
+		return ErrNotSupported
+	}
+	if d.Ata.SecurityFrozen {
+		return ErrSecurityFrozen
+	}
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return err
+	}
+	var busy []string
+	for _, b := range s.BlockDevicesOnDrive(d.Id) {
+		if b.IsMounted() || b.CryptoBackingDevice != nil {
+			busy = append(busy, b.Device)
+		}
+	}
+	if len(busy) > 0 {
+		return &DriveInUseError{Devices: busy}
+	}
+	opt := defaultOptions()
+	if enhanced {
+		opt["enhanced"] = true
+	}
+	if opts.Started != nil {
+		opts.Started(d.Ata.SecurityEraseDuration(enhanced))
+	}
+	return c.callNoDefaultTimeout(ctx, dbus.ObjectPath(d.Path), "org.freedesktop.UDisks2.Drive.Ata.SecurityEraseUnit", opt).Err
+}
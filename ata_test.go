@@ -0,0 +1,134 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestAtaSmartAttributes(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{
+		Id:  "ATA-Disk",
+		Ata: udiskstest.Props{"SmartSupported": true, "SmartEnabled": true},
+		SmartAttributes: []udiskstest.SmartAttribute{
+			{ID: 5, Name: "reallocated-sector-count", Flags: 0x33, Value: 5, Worst: 5, Threshold: 10, Pretty: 1200, PrettyUnit: 3},
+			{ID: 9, Name: "power-on-hours", Flags: 0x32, Value: 90, Worst: 90, Threshold: 0, Pretty: 3600000, PrettyUnit: 2},
+			{ID: 194, Name: "temperature-celsius-2", Flags: 0x22, Value: 60, Worst: 40, Threshold: 0, Pretty: 308150, PrettyUnit: 4},
+		},
+	})
+
+	attrs, err := c.AtaSmartAttributes(drive(t, c, "ATA-Disk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 3 {
+		t.Fatalf("got %d attributes, want 3", len(attrs))
+	}
+	realloc, hours, temp := attrs[0], attrs[1], attrs[2]
+	if realloc.ID != 5 || !realloc.PreFail() || !realloc.Failing() || realloc.PrettyString() != "1200 sectors" {
+		t.Errorf("unexpected attribute %+v", realloc)
+	}
+	if !hours.OldAge() || hours.Failing() || hours.Duration() != time.Hour {
+		t.Errorf("unexpected attribute %+v", hours)
+	}
+	if temp.PrettyUnit != udisks.SmartUnitMillikelvin || temp.PrettyString() != "35.0 C" {
+		t.Errorf("unexpected attribute %+v, %s", temp, temp.PrettyString())
+	}
+}
+
+func TestSmartAttributePrettyString(t *testing.T) {
+	for _, a := range []struct {
+		pretty int64
+		unit   udisks.SmartUnit
+		want   string
+	}{
+		{0, udisks.SmartUnitUnknown, "unknown"},
+		{42, udisks.SmartUnitNone, "42"},
+		{1500, udisks.SmartUnitMilliseconds, "1.5s"},
+		{12, udisks.SmartUnitSectors, "12 sectors"},
+		{308150, udisks.SmartUnitMillikelvin, "35.0 C"},
+		{2, udisks.SmartUnitSmallPercent, "2.000%"},
+		{98, udisks.SmartUnitPercent, "98%"},
+		{52345, udisks.SmartUnitMegabytes, "52345 MB"},
+		{1, udisks.SmartUnit(8), "unknown"},
+	} {
+		attr := udisks.SmartAttribute{Pretty: a.pretty, PrettyUnit: a.unit}
+		if got := attr.PrettyString(); got != a.want {
+			t.Errorf("PrettyString of %d in unit %d returned %q, want %q", a.pretty, a.unit, got, a.want)
+		}
+	}
+}
+
+func TestAtaSmartAttributesNotSupported(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "USB-Stick"})
+	srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{"SmartSupported": false}})
+
+	for _, id := range []string{"USB-Stick", "ATA-Disk"} {
+		if _, err := c.AtaSmartAttributes(drive(t, c, id)); !errors.Is(err, udisks.ErrSmartNotSupported) {
+			t.Errorf("%s: got %v, want ErrSmartNotSupported", id, err)
+		}
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Drive.Ata", "SmartGetAttributes"); len(calls) != 0 {
+		t.Error("SmartGetAttributes called on a drive without SMART")
+	}
+}
+
+func TestAtaSecurityErase(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{"SecurityEraseUnitMinutes": int32(60), "SecurityEnhancedEraseUnitMinutes": int32(0)}})
+	p := srv.AddBlock(udiskstest.Block{Name: "sda1", Drive: drv, IdUsage: "filesystem", IdType: "ext4", Filesystem: udiskstest.Props{}})
+	d := drive(t, c, "ATA-Disk")
+
+	if got := d.Ata.SecurityEraseDuration(false); got != time.Hour {
+		t.Errorf("SecurityEraseDuration %v, want 1h", got)
+	}
+	if err := c.AtaSecurityErase(d, true, udisks.SecurityEraseOptions{}); !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("enhanced erase returned %v, want ErrNotSupported", err)
+	}
+	var expected time.Duration
+	err := c.AtaSecurityErase(d, false, udisks.SecurityEraseOptions{Started: func(d time.Duration) {
+		if calls := srv.CallsTo("org.freedesktop.UDisks2.Drive.Ata", "SecurityEraseUnit"); len(calls) != 0 {
+			t.Error("Started called after the erase started")
+		}
+		expected = d
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected != time.Hour {
+		t.Errorf("Started reported %v, want 1h", expected)
+	}
+	if b := blockDevice(t, c, p); b.IdUsage != "" || len(b.Filesystems) != 0 {
+		t.Errorf("block device not erased: %+v", b)
+	}
+	opts := srv.CallsTo("org.freedesktop.UDisks2.Drive.Ata", "SecurityEraseUnit")[0].Args[0].(map[string]dbus.Variant)
+	if _, ok := opts["enhanced"]; ok {
+		t.Errorf("enhanced option sent for a normal erase: %v", opts)
+	}
+}
+
+func TestAtaSecurityEraseRefused(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "Frozen-Disk", Ata: udiskstest.Props{"SecurityEraseUnitMinutes": int32(2), "SecurityFrozen": true}})
+	drv := srv.AddDrive(udiskstest.Drive{Id: "Busy-Disk", Ata: udiskstest.Props{"SecurityEraseUnitMinutes": int32(2)}})
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", Drive: drv, IdUsage: "filesystem", Filesystem: udiskstest.Props{"MountPoints": mounted([]byte("/home"))}})
+
+	opts := udisks.SecurityEraseOptions{Started: func(time.Duration) { t.Error("Started called for a refused erase") }}
+	if err := c.AtaSecurityErase(drive(t, c, "Frozen-Disk"), false, opts); !errors.Is(err, udisks.ErrSecurityFrozen) {
+		t.Errorf("erase of a frozen drive returned %v, want ErrSecurityFrozen", err)
+	}
+	err := c.AtaSecurityErase(drive(t, c, "Busy-Disk"), false, opts)
+	var e *udisks.DriveInUseError
+	if !errors.As(err, &e) || len(e.Devices) != 1 || e.Devices[0] != string(p) || !errors.Is(err, udisks.ErrDeviceBusy) {
+		t.Errorf("erase of a drive in use returned %v, want a *DriveInUseError", err)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Drive.Ata", "SecurityEraseUnit"); len(calls) != 0 {
+		t.Error("SecurityEraseUnit called although the erase was refused")
+	}
+}
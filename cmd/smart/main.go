@@ -18,6 +18,13 @@ func main() {
 	for _, v := range drives {
 		if v.Ata != nil {
 			fmt.Println(v.Id, "ATA SMART", v.Ata)
+			attrs, err := client.AtaSmartAttributes(v)
+			if err != nil {
+				fmt.Println(v.Id, err)
+			}
+			for _, a := range attrs {
+				fmt.Printf("  %3d %-28s %3d %3d %3d %s\n", a.ID, a.Name, a.Value, a.Worst, a.Threshold, a.PrettyString())
+			}
 		}
 		if v.NVMeController != nil {
 			fmt.Println(v.Id, "NVME SMART", v.NVMeController)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -35,6 +36,14 @@ func main() {
 			panic(err)
 		}
 		pretty(drives)
+	case "watch":
+		events, err := client.Watch(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		for ev := range events {
+			fmt.Println(ev.Type, ev.Path)
+		}
 	default:
 		usage()
 	}
@@ -46,6 +55,6 @@ func pretty(dev interface{}) {
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: udisks <blkdevs|drives>\n")
+	fmt.Fprintf(os.Stderr, "Usage: udisks <blkdevs|drives|watch>\n")
 	os.Exit(2)
 }
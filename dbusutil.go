@@ -1,13 +1,22 @@
 package udisks
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
 	"github.com/godbus/dbus/v5"
 )
 
-func prop[T any](path string, obj dbus.BusObject, p *T) error {
-	v, err := obj.GetProperty(path)
-	if err != nil {
-		return err
+// managedObjects is the reply of org.freedesktop.DBus.ObjectManager.GetManagedObjects:
+// object path -> interface name -> property name -> value.
+type managedObjects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+
+func prop[T any](props map[string]dbus.Variant, name string, p *T) error {
+	v, ok := props[name]
+	if !ok {
+		return nil
 	}
 	t, ok := v.Value().(T)
 	if !ok {
@@ -16,247 +25,168 @@ func prop[T any](path string, obj dbus.BusObject, p *T) error {
 	*p = t
 	return nil
 }
-func stringArrayPropertyFromByte(path string, obj dbus.BusObject, p *[]string) error {
-	v, err := obj.GetProperty(path)
-	if err != nil {
-		return err
-	}
-	t, ok := v.Value().([][]uint8)
+
+// storeProperty decodes properties holding structs or arrays of structs, which
+// godbus returns as []interface{}, into p
+func storeProperty(props map[string]dbus.Variant, name string, p interface{}) error {
+	v, ok := props[name]
 	if !ok {
-		return ErrInvalidPropertyFormat
+		return nil
 	}
-	*p = make([]string, len(t))
-	for i, v := range t {
-		acc := *p
-		acc[i] = string(v)
+	if err := dbus.Store([]interface{}{v.Value()}, p); err != nil {
+		return ErrInvalidPropertyFormat
 	}
 	return nil
 }
-func stringProperty(path string, obj dbus.BusObject, p *string) error {
-	v, err := obj.GetProperty(path)
-	if err != nil {
-		return err
-	}
 
-	var ok bool
-	*p, ok = v.Value().(string)
-	if !ok {
-		return ErrInvalidPropertyFormat
+// byteStringProperty decodes a NUL terminated byte array (D-Bus type ay) as used
+// by UDisks2 for file system paths.
+func byteStringProperty(props map[string]dbus.Variant, name string, p *string) error {
+	var b []byte
+	if err := prop(props, name, &b); err != nil {
+		return err
 	}
-
+	*p = byteString(b)
 	return nil
 }
 
-func boolProperty(path string, obj dbus.BusObject, p *bool) error {
-	v, err := obj.GetProperty(path)
-	if err != nil {
+// byteStringArrayProperty decodes an array of NUL terminated byte arrays (D-Bus type aay).
+func byteStringArrayProperty(props map[string]dbus.Variant, name string, p *[]string) error {
+	var t [][]byte
+	if err := prop(props, name, &t); err != nil {
 		return err
 	}
-
-	var ok bool
-	*p, ok = v.Value().(bool)
-	if !ok {
-		return ErrInvalidPropertyFormat
+	*p = make([]string, len(t))
+	for i, v := range t {
+		acc := *p
+		acc[i] = byteString(v)
 	}
-
 	return nil
 }
 
-func uint64Property(path string, obj dbus.BusObject, p *uint64) error {
-	v, err := obj.GetProperty(path)
-	if err != nil {
-		return err
+func byteString(b []byte) string {
+	if len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
 	}
+	return string(b)
+}
 
-	var ok bool
-	*p, ok = v.Value().(uint64)
-	if !ok {
-		return ErrInvalidPropertyFormat
+// objectPathProperty returns the object path stored in the property, or the
+// empty string when the property is missing or points at "/".
+func objectPathProperty(props map[string]dbus.Variant, name string) dbus.ObjectPath {
+	var path dbus.ObjectPath
+	prop(props, name, &path)
+	if path == "/" || !path.IsValid() {
+		return ""
 	}
+	return path
+}
 
+// timeProperty decodes a timestamp in microseconds since the epoch. 0 means
+// unknown and leaves the zero time.
+func timeProperty(props map[string]dbus.Variant, name string, p *time.Time) error {
+	var usec uint64
+	if err := prop(props, name, &usec); err != nil {
+		return err
+	}
+	if usec != 0 {
+		*p = time.UnixMicro(int64(usec))
+	}
 	return nil
 }
-func objGet(conn *dbus.Conn, property string, obj dbus.BusObject) (dbus.BusObject, error) {
-	v, err := obj.GetProperty(property)
+
+func (c *Client) managedObjects(ctx context.Context) (managedObjects, error) {
+	var objs map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err := c.call(ctx, "/org/freedesktop/UDisks2", "org.freedesktop.DBus.ObjectManager.GetManagedObjects").Store(&objs)
 	if err != nil {
 		return nil, err
 	}
+	return objs, nil
+}
 
-	var ok bool
-	path, ok := v.Value().(dbus.ObjectPath)
-	if !ok {
-		return nil, ErrInvalidPropertyFormat
+// defaultOptions returns the options of calls that take none from the caller.
+// udisksd must never prompt for authentication on their behalf.
+func defaultOptions() map[string]interface{} {
+	return map[string]interface{}{
+		"auth.no_user_interaction": true,
 	}
-	driveObj := conn.Object("org.freedesktop.UDisks2", path)
-
-	return driveObj, nil
 }
 
-func (c *Client) getDrive(blkobj dbus.BusObject) (*Drive, error) {
-	objDrv, err := objGet(c.conn, "org.freedesktop.UDisks2.Block.Drive", blkobj)
-	if err != nil || objDrv.Path() == dbus.ObjectPath("/") {
-		return nil, ErrInvalidDrive
+// call invokes a method on a UDisks object, turning D-Bus error replies into *Error.
+// The default timeout of the client applies if ctx has no deadline.
+func (c *Client) call(ctx context.Context, path dbus.ObjectPath, method string, args ...interface{}) *dbus.Call {
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
 	}
+	return c.callNoDefaultTimeout(ctx, path, method, args...)
+}
 
-	return c.buildDrive(objDrv)
+// callNoDefaultTimeout is call without the default timeout of the client. It
+// is used for operations that may run for hours, which only ctx can bound.
+func (c *Client) callNoDefaultTimeout(ctx context.Context, path dbus.ObjectPath, method string, args ...interface{}) *dbus.Call {
+	call := c.conn.Object("org.freedesktop.UDisks2", path).CallWithContext(ctx, method, 0, args...)
+	call.Err = wrapError(path, call.Err)
+	return call
 }
 
-func (c *Client) buildDrive(objDrv dbus.BusObject) (*Drive, error) {
-	drv := &Drive{
-		Vendor:         "",
-		Model:          "",
-		Serial:         "",
-		Id:             "",
-		MediaRemovable: false,
-		Ejectable:      false,
-		MediaAvailable: false,
-	}
-	stringProperty("org.freedesktop.UDisks2.Drive.Vendor", objDrv, &drv.Vendor)
-	stringProperty("org.freedesktop.UDisks2.Drive.Serial", objDrv, &drv.Serial)
-	stringProperty("org.freedesktop.UDisks2.Drive.Model", objDrv, &drv.Model)
-	stringProperty("org.freedesktop.UDisks2.Drive.Id", objDrv, &drv.Id)
-	stringProperty("org.freedesktop.UDisks2.Drive.ConnectionBus", objDrv, &drv.ConnectionBus)
-	stringProperty("org.freedesktop.UDisks2.Drive.Seat", objDrv, &drv.Seat)
-	stringProperty("org.freedesktop.UDisks2.Drive.SiblingId", objDrv, &drv.SiblingId)
-	boolProperty("org.freedesktop.UDisks2.Drive.MediaRemovable", objDrv, &drv.MediaRemovable)
-	boolProperty("org.freedesktop.UDisks2.Drive.MediaAvailable", objDrv, &drv.MediaAvailable)
-	boolProperty("org.freedesktop.UDisks2.Drive.Ejectable", objDrv, &drv.Ejectable)
-	boolProperty("org.freedesktop.UDisks2.Drive.Removable", objDrv, &drv.Removable)
-	uint64Property("org.freedesktop.UDisks2.Drive.Size", objDrv, &drv.Size)
-	boolProperty("org.freedesktop.UDisks2.Drive.CanPowerOff", objDrv, &drv.CanPowerOff)
-	isAtaSmartSupported := false
-	boolProperty("org.freedesktop.UDisks2.Drive.Ata.SmartSupported", objDrv, &isAtaSmartSupported)
-	if isAtaSmartSupported {
-		ata := &Ata{}
-		buildAtaSmart(objDrv, ata)
-		drv.Ata = ata
-	} else {
-		nvmeController := &NVMeController{}
-		if err := buildNVMeSmart(objDrv, nvmeController); err == nil {
-			drv.NVMeController = nvmeController
+// subscribe adds the match rules and returns the channel receiving signals.
+// The returned function removes the rules and the channel again.
+func (c *Client) subscribe(ctx context.Context, matches [][]dbus.MatchOption) (chan *dbus.Signal, func(), error) {
+	unsubscribe := func(n int) {
+		for _, m := range matches[:n] {
+			c.conn.RemoveMatchSignal(m...)
 		}
 	}
-
-	return drv, nil
-}
-func buildAtaSmart(objDrv dbus.BusObject, ata *Ata) error {
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SecurityFrozen", objDrv, &ata.SecurityFrozen); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartSupported", objDrv, &ata.SmartSupported); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartEnabled", objDrv, &ata.SmartEnabled); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartFailing", objDrv, &ata.SmartFailing); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.PmSupported", objDrv, &ata.PmSupported); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.PmEnabled", objDrv, &ata.PmEnabled); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.ApmSupported", objDrv, &ata.ApmSupported); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.ApmEnabled", objDrv, &ata.ApmEnabled); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.WriteCacheSupported", objDrv, &ata.WriteCacheSupported); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.WriteCacheEnabled", objDrv, &ata.WriteCacheEnabled); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.ReadLookaheadSupported", objDrv, &ata.ReadLookaheadSupported); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.ReadLookaheadEnabled", objDrv, &ata.ReadLookaheadEnabled); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartUpdated", objDrv, &ata.SmartUpdated); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartPowerOnSeconds", objDrv, &ata.SmartPowerOnSeconds); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartNumAttributesFailedInThePast", objDrv, &ata.SmartNumAttributesFailedInThePast); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartSelftestPercentRemaining", objDrv, &ata.SmartSelftestPercentRemaining); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.AamVendorRecommendedValue", objDrv, &ata.AamVendorRecommendedValue); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SecurityEraseUnitMinutes", objDrv, &ata.SecurityEraseUnitMinutes); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SecurityEnhancedEraseUnitMinutes", objDrv, &ata.SecurityEnhancedEraseUnitMinutes); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartTemperature", objDrv, &ata.SmartTemperature); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartNumAttributesFailing", objDrv, &ata.SmartNumAttributesFailing); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartSelftestStatus", objDrv, &ata.SmartSelftestStatus); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.Drive.Ata.SmartNumBadSectors", objDrv, &ata.SmartNumBadSectors); err != nil {
-		return err
+	for i, m := range matches {
+		if err := c.conn.AddMatchSignalContext(ctx, m...); err != nil {
+			unsubscribe(i)
+			return nil, nil, err
+		}
 	}
-	return nil
+	sigs := make(chan *dbus.Signal, 256)
+	c.conn.Signal(sigs)
+	return sigs, func() {
+		c.conn.RemoveSignal(sigs)
+		unsubscribe(len(matches))
+	}, nil
 }
-func buildNVMeSmart(objDrv dbus.BusObject, nvme *NVMeController) error {
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SmartSelftestStatus", objDrv, &nvme.SmartSelftestStatus); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SanitizeStatus", objDrv, &nvme.SanitizeStatus); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.FGUID", objDrv, &nvme.FGUID); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.NVMeRevision", objDrv, &nvme.NVMeRevision); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.State", objDrv, &nvme.State); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SmartPowerOnHours", objDrv, &nvme.SmartPowerOnHours); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.UnallocatedCapacity", objDrv, &nvme.UnallocatedCapacity); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SmartUpdated", objDrv, &nvme.SmartUpdated); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SmartTemperature", objDrv, &nvme.SmartTemperature); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.ControllerID", objDrv, &nvme.ControllerID); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SmartSelftestPercentRemaining", objDrv, &nvme.SmartSelftestPercentRemaining); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SanitizePercentRemaining", objDrv, &nvme.SanitizePercentRemaining); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SanitizePercentRemaining", objDrv, &nvme.SanitizePercentRemaining); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SubsystemNQN", objDrv, &nvme.SubsystemNQN); err != nil {
-		return err
-	}
-	if err := prop("org.freedesktop.UDisks2.NVMe.Controller.SmartCriticalWarning", objDrv, &nvme.SmartCriticalWarning); err != nil {
-		return err
-	}
 
-	return nil
+// errSignalsClosed is returned when the signal channel of a subscription is
+// closed, e.g. together with the connection
+var errSignalsClosed = fmt.Errorf("signal channel closed: %w", net.ErrClosed)
+
+// waitPropertiesChanged reads sigs until the properties of iface on the object
+// at path change and merges the changes into props. notFound is returned when
+// the object is removed.
+func waitPropertiesChanged(ctx context.Context, sigs chan *dbus.Signal, path dbus.ObjectPath, iface string, props map[string]dbus.Variant, notFound error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case sig, ok := <-sigs:
+			if !ok {
+				return errSignalsClosed
+			}
+			if len(sig.Body) < 2 {
+				continue
+			}
+			switch sig.Name {
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				if name, _ := sig.Body[0].(string); sig.Path != path || name != iface {
+					continue
+				}
+				changed, _ := sig.Body[1].(map[string]dbus.Variant)
+				for name, v := range changed {
+					props[name] = v
+				}
+				return nil
+			case "org.freedesktop.DBus.ObjectManager.InterfacesRemoved":
+				if removed, _ := sig.Body[0].(dbus.ObjectPath); removed == path {
+					return notFound
+				}
+			}
+		}
+	}
 }
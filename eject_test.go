@@ -0,0 +1,44 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestEject(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "DVD-RW", Ejectable: true, Removable: true, Props: udiskstest.Props{
+		"MediaAvailable":     true,
+		"Media":              "optical_dvd",
+		"MediaCompatibility": []string{"optical_cd", "optical_dvd"},
+		"Optical":            true,
+		"OpticalNumTracks":   uint32(1),
+	}})
+	srv.AddBlock(udiskstest.Block{Name: "sr0", Drive: drv, IdUsage: "filesystem", IdType: "iso9660", Filesystem: udiskstest.Props{"MountPoints": mounted([]byte("/run/media/dvd"))}})
+
+	d := drive(t, c, "DVD-RW")
+	if !d.Optical || d.Media != "optical_dvd" || len(d.MediaCompatibility) != 2 || d.OpticalNumTracks != 1 {
+		t.Errorf("unexpected optical drive %+v", d)
+	}
+	if err := c.Eject(d); err != nil {
+		t.Fatal(err)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Filesystem", "Unmount"); len(calls) != 1 {
+		t.Errorf("got %d Unmount calls, want 1", len(calls))
+	}
+	if d := drive(t, c, "DVD-RW"); d.MediaAvailable || d.Media != "" {
+		t.Errorf("media still available after Eject: %+v", d)
+	}
+}
+
+func TestEjectNotSupported(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "Internal-Disk"})
+
+	if err := c.Eject(drive(t, c, "Internal-Disk")); !errors.Is(err, udisks.ErrEjectNotSupported) {
+		t.Errorf("Eject returned %v, want ErrEjectNotSupported", err)
+	}
+}
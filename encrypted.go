@@ -0,0 +1,76 @@
+package udisks
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// UnlockOptions are the options of org.freedesktop.UDisks2.Encrypted.Unlock.
+// KeyfileContents takes precedence over Passphrase if both are set. Passphrase
+// and KeyfileContents are zeroed once the call returns.
+type UnlockOptions struct {
+	Passphrase      []byte
+	KeyfileContents []byte
+	ReadOnly        bool
+	// Hidden, System, PIM and Keyfiles only apply to TCRYPT (VeraCrypt and
+	// TrueCrypt) containers
+	Hidden   bool
+	System   bool
+	PIM      uint32
+	Keyfiles []string
+	// Interactive allows udisks to ask for authentication through polkit
+	Interactive bool
+}
+
+// Unlock opens the encrypted container on the block device and returns the
+// cleartext block device
+func (c *Client) Unlock(b *BlockDevice, opts UnlockOptions) (*BlockDevice, error) {
+	return c.UnlockContext(context.Background(), b, opts)
+}
+
+func (c *Client) UnlockContext(ctx context.Context, b *BlockDevice, opts UnlockOptions) (*BlockDevice, error) {
+	defer zero(opts.Passphrase)
+	defer zero(opts.KeyfileContents)
+
+	opt := map[string]interface{}{
+		"auth.no_user_interaction": !opts.Interactive,
+	}
+	// udisksd takes the binary keyfile_contents option in place of the
+	// passphrase argument. Sending the passphrase that way avoids an immutable
+	// string copy of the secret that could not be zeroed.
+	secret := opts.KeyfileContents
+	if secret == nil {
+		secret = opts.Passphrase
+	}
+	if secret != nil {
+		opt["keyfile_contents"] = secret
+	}
+	if opts.ReadOnly {
+		opt["read-only"] = true
+	}
+	if opts.Hidden {
+		opt["hidden"] = true
+	}
+	if opts.System {
+		opt["system"] = true
+	}
+	if opts.PIM != 0 {
+		opt["pim"] = opts.PIM
+	}
+	if len(opts.Keyfiles) > 0 {
+		opt["keyfiles"] = opts.Keyfiles
+	}
+	var cleartext dbus.ObjectPath
+	err := c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Encrypted.Unlock", "", opt).Store(&cleartext)
+	if err != nil {
+		return nil, err
+	}
+	return c.blockDevice(ctx, cleartext)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
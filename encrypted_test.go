@@ -0,0 +1,55 @@
+package udisks_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestUnlockSendsSecretAsBytes(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdc1", IdUsage: "crypto", Encrypted: udiskstest.Props{}, Passphrase: "secret"})
+
+	passphrase := []byte("secret")
+	if _, err := c.Unlock(blockDevice(t, c, p), udisks.UnlockOptions{Passphrase: passphrase, ReadOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(passphrase, make([]byte, len(passphrase))) {
+		t.Errorf("passphrase not zeroed after Unlock: %q", passphrase)
+	}
+
+	calls := srv.CallsTo("org.freedesktop.UDisks2.Encrypted", "Unlock")
+	if len(calls) != 1 {
+		t.Fatalf("got %d Unlock calls, want 1", len(calls))
+	}
+	if calls[0].Args[0] != "" {
+		t.Errorf("passphrase argument %q, want it empty", calls[0].Args[0])
+	}
+	opts := calls[0].Args[1].(map[string]dbus.Variant)
+	if got, _ := opts["keyfile_contents"].Value().([]byte); string(got) != "secret" {
+		t.Errorf("keyfile_contents %q, want secret", got)
+	}
+	if opts["read-only"].Value() != true {
+		t.Errorf("read-only option %v, want true", opts["read-only"])
+	}
+}
+
+func TestUnlockKeyfileContents(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdc1", IdUsage: "crypto", Encrypted: udiskstest.Props{}, Passphrase: "key\x00data"})
+
+	key := []byte("key\x00data")
+	cleartext, err := c.Unlock(blockDevice(t, c, p), udisks.UnlockOptions{Passphrase: []byte("ignored"), KeyfileContents: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleartext.CryptoBackingDevice == nil || cleartext.CryptoBackingDevice.Path != string(p) {
+		t.Errorf("cleartext device is not linked to %s", p)
+	}
+	if !bytes.Equal(key, make([]byte, len(key))) {
+		t.Errorf("keyfile contents not zeroed after Unlock: %q", key)
+	}
+}
@@ -1,10 +1,104 @@
 package udisks
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+)
 
 var ErrInvalidDrive = errors.New("invalid drive")
 var ErrDriveNotFound = errors.New("drive not found")
 var ErrUnmountFailed = errors.New("unmount failed")
 var ErrLockingFailed = errors.New("locking failed")
+var ErrSwapoffFailed = errors.New("swapoff failed")
 var ErrPowerOffNotSupported = errors.New("power off not supported for this drive")
+var ErrEjectNotSupported = errors.New("eject not supported for this drive")
 var ErrInvalidPropertyFormat = errors.New("invalid property format")
+var ErrSecurityFrozen = errors.New("drive security is frozen")
+var ErrNoFilesystem = errors.New("block device has no file system")
+var ErrSmartNotSupported = errors.New("SMART not supported by this drive")
+var ErrNoClient = errors.New("object is not associated with a client")
+var ErrInvalidOption = errors.New("invalid option")
+
+// Errors reported by udisksd, see Error
+var ErrFailed = errors.New("operation failed")
+var ErrCancelled = errors.New("operation cancelled")
+var ErrAlreadyCancelled = errors.New("operation already cancelled")
+var ErrNotAuthorized = errors.New("not authorized")
+var ErrNotAuthorizedCanObtain = errors.New("not authorized, authorization can be obtained")
+var ErrNotAuthorizedDismissed = errors.New("authorization dismissed")
+var ErrAlreadyMounted = errors.New("already mounted")
+var ErrNotMounted = errors.New("not mounted")
+var ErrOptionNotPermitted = errors.New("option not permitted")
+var ErrMountedByOtherUser = errors.New("mounted by another user")
+var ErrAlreadyUnmounting = errors.New("already unmounting")
+var ErrNotSupported = errors.New("not supported")
+var ErrTimedOut = errors.New("timed out")
+var ErrWouldWakeup = errors.New("would wake up the device")
+var ErrDeviceBusy = errors.New("device busy")
+var ErrAlreadyUnlocked = errors.New("already unlocked")
+var ErrNotUnlocked = errors.New("not unlocked")
+
+var dbusErrors = map[string]error{
+	"org.freedesktop.UDisks2.Error.Failed":                 ErrFailed,
+	"org.freedesktop.UDisks2.Error.Cancelled":              ErrCancelled,
+	"org.freedesktop.UDisks2.Error.AlreadyCancelled":       ErrAlreadyCancelled,
+	"org.freedesktop.UDisks2.Error.NotAuthorized":          ErrNotAuthorized,
+	"org.freedesktop.UDisks2.Error.NotAuthorizedCanObtain": ErrNotAuthorizedCanObtain,
+	"org.freedesktop.UDisks2.Error.NotAuthorizedDismissed": ErrNotAuthorizedDismissed,
+	"org.freedesktop.UDisks2.Error.AlreadyMounted":         ErrAlreadyMounted,
+	"org.freedesktop.UDisks2.Error.NotMounted":             ErrNotMounted,
+	"org.freedesktop.UDisks2.Error.OptionNotPermitted":     ErrOptionNotPermitted,
+	"org.freedesktop.UDisks2.Error.MountedByOtherUser":     ErrMountedByOtherUser,
+	"org.freedesktop.UDisks2.Error.AlreadyUnmounting":      ErrAlreadyUnmounting,
+	"org.freedesktop.UDisks2.Error.NotSupported":           ErrNotSupported,
+	"org.freedesktop.UDisks2.Error.Timedout":               ErrTimedOut,
+	"org.freedesktop.UDisks2.Error.WouldWakeup":            ErrWouldWakeup,
+	"org.freedesktop.UDisks2.Error.DeviceBusy":             ErrDeviceBusy,
+	"org.freedesktop.UDisks2.Error.AlreadyUnlocked":        ErrAlreadyUnlocked,
+	"org.freedesktop.UDisks2.Error.NotUnlocked":            ErrNotUnlocked,
+}
+
+// Error is a D-Bus error returned by udisksd. It matches the sentinel error
+// for its name with errors.Is, e.g. errors.Is(err, ErrDeviceBusy), and
+// unwraps to the original dbus.Error.
+type Error struct {
+	// Name is the D-Bus error name such as org.freedesktop.UDisks2.Error.DeviceBusy
+	Name    string
+	Message string
+	// Path is the object the failed method was called on
+	Path string
+	err  dbus.Error
+}
+
+func (e *Error) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	sentinel, ok := dbusErrors[e.Name]
+	return ok && sentinel == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// wrapError turns D-Bus error replies into *Error and returns other errors unchanged
+func wrapError(path dbus.ObjectPath, err error) error {
+	var dbusErr dbus.Error
+	switch e := err.(type) {
+	case dbus.Error:
+		dbusErr = e
+	case *dbus.Error:
+		dbusErr = *e
+	default:
+		return err
+	}
+	return &Error{
+		Name:    dbusErr.Name,
+		Message: dbusErr.Error(),
+		Path:    string(path),
+		err:     dbusErr,
+	}
+}
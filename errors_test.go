@@ -0,0 +1,81 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestErrorMapping(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", Filesystem: udiskstest.Props{}})
+
+	for name, want := range map[string]error{
+		"org.freedesktop.UDisks2.Error.DeviceBusy":    udisks.ErrDeviceBusy,
+		"org.freedesktop.UDisks2.Error.NotAuthorized": udisks.ErrNotAuthorized,
+		"org.freedesktop.UDisks2.Error.Timedout":      udisks.ErrTimedOut,
+	} {
+		name := name
+		srv.Handle("org.freedesktop.UDisks2.Filesystem", "Unmount", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+			return nil, udiskstest.NewError(name, "failed")
+		})
+		err := c.UnmountBlockDevice(string(p))
+		if !errors.Is(err, want) {
+			t.Errorf("%s: got %v, want %v", name, err, want)
+		}
+		var dbusErr dbus.Error
+		if !errors.As(err, &dbusErr) || dbusErr.Name != name {
+			t.Errorf("%s: error does not unwrap to the dbus.Error: %v", name, err)
+		}
+	}
+
+	srv.Handle("org.freedesktop.UDisks2.Filesystem", "Unmount", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		return nil, udiskstest.NewError("org.freedesktop.UDisks2.Error.SomethingNew", "failed")
+	})
+	var e *udisks.Error
+	if err := c.UnmountBlockDevice(string(p)); !errors.As(err, &e) || errors.Is(err, udisks.ErrFailed) {
+		t.Errorf("unknown error name not kept as a plain *udisks.Error: %v", err)
+	}
+}
+
+func TestPowerOffTearDownErrors(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "USB-Stick", CanPowerOff: true})
+	fs := srv.AddBlock(udiskstest.Block{Name: "sdd1", Drive: drv, IdUsage: "filesystem", Filesystem: udiskstest.Props{"MountPoints": mounted([]byte("/mnt"))}})
+	srv.AddBlock(udiskstest.Block{Name: "sdd2", Drive: drv, IdUsage: "filesystem", Filesystem: udiskstest.Props{}})
+
+	srv.Handle("org.freedesktop.UDisks2.Filesystem", "Unmount", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		return nil, udiskstest.NewError("org.freedesktop.UDisks2.Error.DeviceBusy", "target is busy")
+	})
+	err := c.PowerOff(drive(t, c, "USB-Stick"))
+	if !errors.Is(err, udisks.ErrUnmountFailed) || !errors.Is(err, udisks.ErrDeviceBusy) {
+		t.Fatalf("PowerOff returned %v, want ErrUnmountFailed and ErrDeviceBusy", err)
+	}
+	// only the mounted file system is unmounted, by its object path
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Filesystem", "Unmount"); len(calls) != 1 || calls[0].Path != fs {
+		t.Errorf("unexpected Unmount calls %v", calls)
+	}
+	if !srv.HasObject(drv) {
+		t.Error("drive powered off although the teardown failed")
+	}
+}
+
+func TestPowerOffLockError(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "USB-Stick", CanPowerOff: true})
+	crypt := srv.AddBlock(udiskstest.Block{Name: "sdd1", Drive: drv, IdUsage: "crypto", Encrypted: udiskstest.Props{}, Passphrase: "secret"})
+	if _, err := c.Unlock(blockDevice(t, c, crypt), udisks.UnlockOptions{Passphrase: []byte("secret")}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Handle("org.freedesktop.UDisks2.Encrypted", "Lock", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		return nil, udiskstest.NewError("org.freedesktop.UDisks2.Error.DeviceBusy", "device in use")
+	})
+	err := c.PowerOff(drive(t, c, "USB-Stick"))
+	if !errors.Is(err, udisks.ErrLockingFailed) || errors.Is(err, udisks.ErrUnmountFailed) {
+		t.Errorf("PowerOff returned %v, want ErrLockingFailed", err)
+	}
+}
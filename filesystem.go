@@ -0,0 +1,272 @@
+package udisks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ResizeMode tells how a file system can be resized, see CanResize
+type ResizeMode uint64
+
+const (
+	ResizeOfflineShrink ResizeMode = 1 << 1
+	ResizeOfflineGrow   ResizeMode = 1 << 2
+	ResizeOnlineShrink  ResizeMode = 1 << 3
+	ResizeOnlineGrow    ResizeMode = 1 << 4
+)
+
+// MountOptions are the options of org.freedesktop.UDisks2.Filesystem.Mount
+type MountOptions struct {
+	// FSType overrides the detected file system type
+	FSType string
+	// Options are the comma separated mount options, e.g. "ro,noexec,uid=1000"
+	Options string
+	// AsUser mounts the file system on behalf of another user
+	AsUser string
+	// Interactive allows udisks to ask for authentication through polkit
+	Interactive bool
+}
+
+// Mount mounts the file system of the block device and returns the mount point
+// chosen by udisks. The Filesystems of b are refreshed afterwards.
+func (c *Client) Mount(b *BlockDevice, opts MountOptions) (string, error) {
+	return c.MountContext(context.Background(), b, opts)
+}
+
+func (c *Client) MountContext(ctx context.Context, b *BlockDevice, opts MountOptions) (string, error) {
+	opt := map[string]interface{}{
+		"auth.no_user_interaction": !opts.Interactive,
+	}
+	if opts.FSType != "" {
+		opt["fstype"] = opts.FSType
+	}
+	if opts.Options != "" {
+		opt["options"] = opts.Options
+	}
+	if opts.AsUser != "" {
+		opt["as-user"] = opts.AsUser
+	}
+	var mountPath string
+	err := c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Filesystem.Mount", opt).Store(&mountPath)
+	if err != nil {
+		return "", err
+	}
+	if err := c.refreshFilesystems(ctx, b); err != nil {
+		return mountPath, err
+	}
+	// udisks may return before the MountPoints property is updated
+	if !b.IsMounted() && len(b.Filesystems) > 0 {
+		b.Filesystems[0].MountPoints = append(b.Filesystems[0].MountPoints, mountPath)
+	}
+	return mountPath, nil
+}
+
+// refreshFilesystems reloads the org.freedesktop.UDisks2.Filesystem properties of b
+func (c *Client) refreshFilesystems(ctx context.Context, b *BlockDevice) error {
+	var props map[string]dbus.Variant
+	err := c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.DBus.Properties.GetAll", "org.freedesktop.UDisks2.Filesystem").Store(&props)
+	if err != nil {
+		return err
+	}
+	b.Filesystems = nil
+	if len(props) != 0 {
+		b.Filesystems = append(b.Filesystems, buildFilesystem(props))
+	}
+	return nil
+}
+
+// CanCheck reports whether udisks is able to check file systems of fsType. If
+// not, the name of the missing utility is returned.
+func (c *Client) CanCheck(fsType string) (bool, string, error) {
+	return c.CanCheckContext(context.Background(), fsType)
+}
+
+func (c *Client) CanCheckContext(ctx context.Context, fsType string) (bool, string, error) {
+	return c.canManager(ctx, "CanCheck", fsType)
+}
+
+// CanRepair reports whether udisks is able to repair file systems of fsType. If
+// not, the name of the missing utility is returned.
+func (c *Client) CanRepair(fsType string) (bool, string, error) {
+	return c.CanRepairContext(context.Background(), fsType)
+}
+
+func (c *Client) CanRepairContext(ctx context.Context, fsType string) (bool, string, error) {
+	return c.canManager(ctx, "CanRepair", fsType)
+}
+
+func (c *Client) canManager(ctx context.Context, method string, fsType string) (bool, string, error) {
+	var reply struct {
+		Available bool
+		Utility   string
+	}
+	err := c.call(ctx, "/org/freedesktop/UDisks2/Manager", "org.freedesktop.UDisks2.Manager."+method, fsType).Store(&reply)
+	return reply.Available, reply.Utility, err
+}
+
+// CanResize reports whether udisks is able to resize file systems of fsType and
+// in which modes. If not, the name of the missing utility is returned.
+func (c *Client) CanResize(fsType string) (bool, ResizeMode, string, error) {
+	return c.CanResizeContext(context.Background(), fsType)
+}
+
+func (c *Client) CanResizeContext(ctx context.Context, fsType string) (bool, ResizeMode, string, error) {
+	var reply struct {
+		Available bool
+		Mode      uint64
+		Utility   string
+	}
+	err := c.call(ctx, "/org/freedesktop/UDisks2/Manager", "org.freedesktop.UDisks2.Manager.CanResize", fsType).Store(&reply)
+	return reply.Available, ResizeMode(reply.Mode), reply.Utility, err
+}
+
+// Check checks the file system of the block device, which must not be
+// mounted, and reports whether it is consistent. On large file systems this
+// takes long, the default timeout of the client does not apply.
+func (c *Client) Check(b *BlockDevice) (bool, error) {
+	return c.CheckContext(context.Background(), b)
+}
+
+func (c *Client) CheckContext(ctx context.Context, b *BlockDevice) (bool, error) {
+	if err := c.checkUtility(ctx, b, "check", c.CanCheckContext); err != nil {
+		return false, err
+	}
+	var consistent bool
+	err := c.callNoDefaultTimeout(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Filesystem.Check", defaultOptions()).Store(&consistent)
+	return consistent, err
+}
+
+// Repair repairs the file system of the block device, which must not be
+// mounted, and reports whether it was successful. Like Check it runs without
+// the default timeout of the client.
+func (c *Client) Repair(b *BlockDevice) (bool, error) {
+	return c.RepairContext(context.Background(), b)
+}
+
+func (c *Client) RepairContext(ctx context.Context, b *BlockDevice) (bool, error) {
+	if err := c.checkUtility(ctx, b, "repair", c.CanRepairContext); err != nil {
+		return false, err
+	}
+	var repaired bool
+	err := c.callNoDefaultTimeout(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Filesystem.Repair", defaultOptions()).Store(&repaired)
+	return repaired, err
+}
+
+// Resize changes the size of the file system of the block device in bytes, 0
+// fills the block device. ErrNotSupported is returned if the file system cannot
+// be resized in the required mode, e.g. shrunk while mounted. Shrinking moves
+// data, so only ctx and not the default timeout bounds the call.
+func (c *Client) Resize(b *BlockDevice, size uint64) error {
+	return c.ResizeContext(context.Background(), b, size)
+}
+
+func (c *Client) ResizeContext(ctx context.Context, b *BlockDevice, size uint64) error {
+	if err := c.checkResize(ctx, b, size); err != nil {
+		return err
+	}
+	err := c.callNoDefaultTimeout(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Filesystem.Resize", size, defaultOptions()).Err
+	if err != nil {
+		return err
+	}
+	return c.refreshFilesystems(ctx, b)
+}
+
+// SetLabel changes the label of the file system of the block device and
+// updates IdLabel of b
+func (c *Client) SetLabel(b *BlockDevice, label string) error {
+	return c.SetLabelContext(context.Background(), b, label)
+}
+
+func (c *Client) SetLabelContext(ctx context.Context, b *BlockDevice, label string) error {
+	err := c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Filesystem.SetLabel", label, defaultOptions()).Err
+	if err == nil {
+		b.IdLabel = label
+	}
+	return err
+}
+
+// SetUUID changes the UUID of the file system of the block device, not all file
+// system types support it. UUID of b is updated.
+func (c *Client) SetUUID(b *BlockDevice, uuid string) error {
+	return c.SetUUIDContext(context.Background(), b, uuid)
+}
+
+func (c *Client) SetUUIDContext(ctx context.Context, b *BlockDevice, uuid string) error {
+	err := c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Filesystem.SetUUID", uuid, defaultOptions()).Err
+	if err == nil {
+		b.UUID = uuid
+	}
+	return err
+}
+
+// TakeOwnership changes the owner of the root directory of the mounted file
+// system to the calling user, with recursive of every file on it
+func (c *Client) TakeOwnership(b *BlockDevice, recursive bool) error {
+	return c.TakeOwnershipContext(context.Background(), b, recursive)
+}
+
+func (c *Client) TakeOwnershipContext(ctx context.Context, b *BlockDevice, recursive bool) error {
+	opt := defaultOptions()
+	if recursive {
+		opt["recursive"] = true
+	}
+	return c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Filesystem.TakeOwnership", opt).Err
+}
+
+// checkResize verifies that udisks can resize the file system of b to size in
+// its current state
+func (c *Client) checkResize(ctx context.Context, b *BlockDevice, size uint64) error {
+	if len(b.Filesystems) == 0 {
+		return ErrNoFilesystem
+	}
+	available, mode, utility, err := c.CanResizeContext(ctx, b.IdType)
+	if err != nil {
+		if isUnknownMethod(err) {
+			return nil
+		}
+		return err
+	}
+	if !available {
+		return &MissingUtilityError{Op: "resize", FSType: b.IdType, Utility: utility}
+	}
+	if b.Filesystems[0].Size == 0 {
+		// the current size is unknown, so is whether this shrinks or grows
+		return nil
+	}
+	shrink := size != 0 && size < b.Filesystems[0].Size
+	need, desc := ResizeOfflineGrow, "offline grow"
+	switch {
+	case b.IsMounted() && shrink:
+		need, desc = ResizeOnlineShrink, "online shrink"
+	case b.IsMounted():
+		need, desc = ResizeOnlineGrow, "online grow"
+	case shrink:
+		need, desc = ResizeOfflineShrink, "offline shrink"
+	}
+	if mode&need == 0 {
+		return fmt.Errorf("%w: %s does not support %s", ErrNotSupported, b.IdType, desc)
+	}
+	return nil
+}
+
+// checkUtility returns a *MissingUtilityError if udisks lacks the tool to run op
+// on the file system of b
+func (c *Client) checkUtility(ctx context.Context, b *BlockDevice, op string, can func(context.Context, string) (bool, string, error)) error {
+	if len(b.Filesystems) == 0 {
+		return ErrNoFilesystem
+	}
+	available, utility, err := can(ctx, b.IdType)
+	if err != nil {
+		// the Can* methods are not available before udisks 2.9
+		if isUnknownMethod(err) {
+			return nil
+		}
+		return err
+	}
+	if !available {
+		return &MissingUtilityError{Op: op, FSType: b.IdType, Utility: utility}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestCheckRepair(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", IdType: "ext4", Filesystem: udiskstest.Props{}})
+	b := blockDevice(t, c, p)
+
+	if ok, utility, err := c.CanCheck("ext4"); err != nil || !ok || utility != "e2fsck" {
+		t.Errorf("CanCheck returned %v, %q, %v", ok, utility, err)
+	}
+	if consistent, err := c.Check(b); err != nil || !consistent {
+		t.Errorf("Check returned %v, %v", consistent, err)
+	}
+	if repaired, err := c.Repair(b); err != nil || !repaired {
+		t.Errorf("Repair returned %v, %v", repaired, err)
+	}
+
+	srv.SetProperty(p, "org.freedesktop.UDisks2.Filesystem", "MountPoints", mounted([]byte("/mnt")))
+	if _, err := c.Check(blockDevice(t, c, p)); !errors.Is(err, udisks.ErrDeviceBusy) {
+		t.Errorf("Check of a mounted file system returned %v, want ErrDeviceBusy", err)
+	}
+}
+
+func TestCheckMissingUtility(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.SetProperty("/org/freedesktop/UDisks2/Manager", "org.freedesktop.UDisks2.Manager", "SupportedFilesystems", []string{"ext4"})
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", IdType: "xfs", Filesystem: udiskstest.Props{}})
+
+	_, err := c.Check(blockDevice(t, c, p))
+	var e *udisks.MissingUtilityError
+	if !errors.As(err, &e) || e.Op != "check" || e.FSType != "xfs" || e.Utility != "xfs_repair" {
+		t.Fatalf("Check returned %v, want a *MissingUtilityError", err)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Filesystem", "Check"); len(calls) != 0 {
+		t.Errorf("Check called although the utility is missing")
+	}
+}
+
+func TestResize(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", Size: 1 << 30, IdUsage: "filesystem", IdType: "ext4", Filesystem: udiskstest.Props{"Size": uint64(1 << 29)}})
+	b := blockDevice(t, c, p)
+
+	if ok, mode, _, err := c.CanResize("ext4"); err != nil || !ok || mode&udisks.ResizeOnlineGrow == 0 {
+		t.Errorf("CanResize returned %v, %v, %v", ok, mode, err)
+	}
+	if err := c.Resize(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if b.Filesystems[0].Size != 1<<30 {
+		t.Errorf("file system size %d after Resize, want %d", b.Filesystems[0].Size, 1<<30)
+	}
+
+	srv.SetProperty(p, "org.freedesktop.UDisks2.Filesystem", "MountPoints", mounted([]byte("/mnt")))
+	if err := c.Resize(blockDevice(t, c, p), 1<<28); !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("online shrink of ext4 returned %v, want ErrNotSupported", err)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Filesystem", "Resize"); len(calls) != 1 {
+		t.Errorf("got %d Resize calls, want 1", len(calls))
+	}
+}
+
+func TestResizeUnknownSize(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", Size: 1 << 30, IdUsage: "filesystem", IdType: "xfs", Filesystem: udiskstest.Props{"Size": uint64(0)}})
+
+	// xfs cannot grow offline, but without the current size the direction is unknown
+	if err := c.Resize(blockDevice(t, c, p), 1<<29); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := srv.Property(p, "org.freedesktop.UDisks2.Filesystem", "Size"); v != uint64(1<<29) {
+		t.Errorf("file system size %v after Resize, want %d", v, 1<<29)
+	}
+}
+
+func TestSetLabelSetUUID(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", IdType: "ext4", IdLabel: "old", Filesystem: udiskstest.Props{}})
+	b := blockDevice(t, c, p)
+
+	if err := c.SetLabel(b, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if b.IdLabel != "new" || blockDevice(t, c, p).IdLabel != "new" {
+		t.Errorf("label %q after SetLabel, want new", b.IdLabel)
+	}
+	const uuid = "0b9a4d0e-5c2f-4e4b-9b8a-2f6c1e7d3a11"
+	if err := c.SetUUID(b, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if b.UUID != uuid || blockDevice(t, c, p).UUID != uuid {
+		t.Errorf("UUID %q after SetUUID, want %s", b.UUID, uuid)
+	}
+}
+
+func TestTakeOwnership(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", IdType: "ext4", Filesystem: udiskstest.Props{}})
+
+	if err := c.TakeOwnership(blockDevice(t, c, p), true); !errors.Is(err, udisks.ErrNotMounted) {
+		t.Errorf("TakeOwnership of an unmounted file system returned %v, want ErrNotMounted", err)
+	}
+	srv.SetProperty(p, "org.freedesktop.UDisks2.Filesystem", "MountPoints", mounted([]byte("/mnt")))
+	if err := c.TakeOwnership(blockDevice(t, c, p), true); err != nil {
+		t.Fatal(err)
+	}
+	calls := srv.CallsTo("org.freedesktop.UDisks2.Filesystem", "TakeOwnership")
+	if opts := calls[len(calls)-1].Args[0].(map[string]dbus.Variant); opts["recursive"].Value() != true {
+		t.Errorf("TakeOwnership options %v, want recursive", opts)
+	}
+}
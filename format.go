@@ -0,0 +1,206 @@
+package udisks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var ErrMissingUtility = errors.New("required utility is not installed")
+
+// MissingUtilityError is returned by Format and the file system operations when
+// udisks lacks the tool needed for fsType. It matches ErrMissingUtility with
+// errors.Is.
+type MissingUtilityError struct {
+	// Op is the operation, e.g. "format" or "check"
+	Op      string
+	FSType  string
+	Utility string
+}
+
+func (e *MissingUtilityError) Error() string {
+	return fmt.Sprintf("cannot %s %s: %s is not installed", e.Op, e.FSType, e.Utility)
+}
+
+func (e *MissingUtilityError) Is(target error) bool {
+	return target == ErrMissingUtility
+}
+
+// ConfigurationItem is an entry of /etc/fstab or /etc/crypttab as managed by
+// udisks, such as {Type: "fstab", Details: {"dir": ..., "opts": ...}}
+type ConfigurationItem struct {
+	Type string
+	// Details are kept as sent on the bus, so that items can be passed back
+	// to udisks. Paths and options such as the fstab dir or the crypttab
+	// device are NUL terminated byte strings, use Detail to read them.
+	Details map[string]dbus.Variant
+}
+
+// Detail returns the string or byte string detail name, e.g. Detail("dir") of
+// an fstab item. It returns "" if the detail is missing or of another type.
+func (ci ConfigurationItem) Detail(name string) string {
+	switch v := ci.Details[name].Value().(type) {
+	case []byte:
+		return byteString(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// FormatOptions are the options used when creating a file system.
+// EncryptPassphrase is zeroed once the call returns.
+type FormatOptions struct {
+	Label string
+	// TakeOwnership makes the calling user the owner of the new file system
+	TakeOwnership bool
+	// EncryptPassphrase creates an encrypted container holding the file system
+	EncryptPassphrase []byte
+	// EncryptType is "luks1" or "luks2", the udisks default is used when empty
+	EncryptType string
+	// Erase is "zero", "ata-secure-erase" or "ata-secure-erase-enhanced"
+	Erase string
+	// NoBlock returns as soon as the job is started instead of waiting for it
+	NoBlock bool
+	// NoDiscard skips discarding the device before formatting
+	NoDiscard bool
+	// UpdatePartitionType sets the partition type matching the file system
+	UpdatePartitionType bool
+	// TearDown removes the existing file system, encryption and their
+	// configuration items before formatting
+	TearDown bool
+	// ConfigItems are added to /etc/fstab and /etc/crypttab for the new device
+	ConfigItems []ConfigurationItem
+	// Interactive allows udisks to ask for authentication through polkit
+	Interactive bool
+}
+
+func (o FormatOptions) options() map[string]interface{} {
+	opt := map[string]interface{}{
+		"auth.no_user_interaction": !o.Interactive,
+	}
+	if o.Label != "" {
+		opt["label"] = o.Label
+	}
+	if o.TakeOwnership {
+		opt["take-ownership"] = true
+	}
+	if o.EncryptPassphrase != nil {
+		// udisksd accepts the passphrase as a byte array, which unlike a
+		// string is zeroed along with EncryptPassphrase
+		opt["encrypt.passphrase"] = o.EncryptPassphrase
+	}
+	if o.EncryptType != "" {
+		opt["encrypt.type"] = o.EncryptType
+	}
+	if o.Erase != "" {
+		opt["erase"] = o.Erase
+	}
+	if o.NoBlock {
+		opt["no-block"] = true
+	}
+	if o.NoDiscard {
+		opt["no-discard"] = true
+	}
+	if o.UpdatePartitionType {
+		opt["update-partition-type"] = true
+	}
+	if o.TearDown {
+		opt["tear-down"] = true
+	}
+	if len(o.ConfigItems) > 0 {
+		opt["config-items"] = o.ConfigItems
+	}
+	return opt
+}
+
+// call returns the method used to format with o. Erasing takes as long as
+// writing the whole device, which the default timeout must not cut short.
+func (o FormatOptions) call(c *Client) func(context.Context, dbus.ObjectPath, string, ...interface{}) *dbus.Call {
+	if o.Erase != "" {
+		return c.callNoDefaultTimeout
+	}
+	return c.call
+}
+
+func (o FormatOptions) zero() {
+	zero(o.EncryptPassphrase)
+}
+
+// Format creates a file system of fsType on the block device. fsType can also be
+// "empty" to wipe signatures, or "dos" and "gpt" to create a partition table.
+// A *MissingUtilityError is returned when the mkfs tool for fsType is missing,
+// and ErrNotSupported when udisks before 2.7 does not list fsType.
+// With opts.Erase the whole device is overwritten first, so the call is then
+// not limited by the default timeout of the client.
+func (c *Client) Format(b *BlockDevice, fsType string, opts FormatOptions) error {
+	return c.FormatContext(context.Background(), b, fsType, opts)
+}
+
+func (c *Client) FormatContext(ctx context.Context, b *BlockDevice, fsType string, opts FormatOptions) error {
+	defer opts.zero()
+	if err := c.checkFormat(ctx, fsType); err != nil {
+		return err
+	}
+	return opts.call(c)(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Block.Format", fsType, opts.options()).Err
+}
+
+// CanFormat reports whether udisks is able to create a file system of fsType.
+// If not, the name of the missing utility is returned.
+func (c *Client) CanFormat(fsType string) (bool, string, error) {
+	return c.CanFormatContext(context.Background(), fsType)
+}
+
+func (c *Client) CanFormatContext(ctx context.Context, fsType string) (bool, string, error) {
+	var reply struct {
+		Available bool
+		Utility   string
+	}
+	err := c.call(ctx, "/org/freedesktop/UDisks2/Manager", "org.freedesktop.UDisks2.Manager.CanFormat", fsType).Store(&reply)
+	return reply.Available, reply.Utility, err
+}
+
+func (c *Client) checkFormat(ctx context.Context, fsType string) error {
+	switch fsType {
+	case "empty", "dos", "gpt":
+		return nil
+	}
+	available, utility, err := c.CanFormatContext(ctx, fsType)
+	if err != nil {
+		// CanFormat is not available before udisks 2.7, which only lists the
+		// file systems it knows
+		if isUnknownMethod(err) {
+			return c.checkSupportedFilesystem(ctx, fsType)
+		}
+		return err
+	}
+	if !available {
+		return &MissingUtilityError{Op: "format", FSType: fsType, Utility: utility}
+	}
+	return nil
+}
+
+func (c *Client) checkSupportedFilesystem(ctx context.Context, fsType string) error {
+	var v dbus.Variant
+	err := c.call(ctx, "/org/freedesktop/UDisks2/Manager", "org.freedesktop.DBus.Properties.Get", "org.freedesktop.UDisks2.Manager", "SupportedFilesystems").Store(&v)
+	if err != nil {
+		return err
+	}
+	supported, ok := v.Value().([]string)
+	if !ok {
+		return ErrInvalidPropertyFormat
+	}
+	for _, t := range supported {
+		if t == fsType {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: cannot format %s", ErrNotSupported, fsType)
+}
+
+func isUnknownMethod(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Name == "org.freedesktop.DBus.Error.UnknownMethod"
+}
@@ -0,0 +1,107 @@
+package udisks_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestFormat(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", Size: 1 << 30})
+
+	if err := c.Format(blockDevice(t, c, p), "ext4", udisks.FormatOptions{Label: "backup", TakeOwnership: true}); err != nil {
+		t.Fatal(err)
+	}
+	b := blockDevice(t, c, p)
+	if b.IdUsage != "filesystem" || b.IdType != "ext4" || b.IdLabel != "backup" || len(b.Filesystems) != 1 {
+		t.Errorf("unexpected block device after Format %+v", b)
+	}
+	opts := srv.CallsTo("org.freedesktop.UDisks2.Block", "Format")[0].Args[1].(map[string]dbus.Variant)
+	if opts["take-ownership"].Value() != true || opts["auth.no_user_interaction"].Value() != true {
+		t.Errorf("unexpected format options %v", opts)
+	}
+
+	if err := c.Format(b, "gpt", udisks.FormatOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if pt := blockDevice(t, c, p).PartitionTable; pt == nil || pt.Type != "gpt" {
+		t.Errorf("no gpt partition table after Format: %+v", pt)
+	}
+}
+
+func TestFormatEncrypted(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", Size: 1 << 30})
+
+	passphrase := []byte("secret")
+	if err := c.Format(blockDevice(t, c, p), "ext4", udisks.FormatOptions{EncryptPassphrase: passphrase, EncryptType: "luks2"}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(passphrase, make([]byte, len(passphrase))) {
+		t.Errorf("passphrase not zeroed after Format: %q", passphrase)
+	}
+	opts := srv.CallsTo("org.freedesktop.UDisks2.Block", "Format")[0].Args[1].(map[string]dbus.Variant)
+	if got, ok := opts["encrypt.passphrase"].Value().([]byte); !ok || string(got) != "secret" {
+		t.Errorf("encrypt.passphrase sent as %#v, want the bytes of secret", opts["encrypt.passphrase"].Value())
+	}
+
+	b := blockDevice(t, c, p)
+	if b.IdUsage != "crypto" {
+		t.Fatalf("IdUsage %q after encrypted Format, want crypto", b.IdUsage)
+	}
+	cleartext, err := c.Unlock(b, udisks.UnlockOptions{Passphrase: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleartext.IdType != "ext4" {
+		t.Errorf("cleartext device has file system %q, want ext4", cleartext.IdType)
+	}
+}
+
+func TestFormatMissingUtility(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1"})
+
+	available, utility, err := c.CanFormat("nilfs2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available || utility != "mkfs.nilfs2" {
+		t.Errorf("CanFormat returned %v, %q, want false, mkfs.nilfs2", available, utility)
+	}
+
+	err = c.Format(blockDevice(t, c, p), "nilfs2", udisks.FormatOptions{})
+	if !errors.Is(err, udisks.ErrMissingUtility) {
+		t.Fatalf("Format returned %v, want ErrMissingUtility", err)
+	}
+	var e *udisks.MissingUtilityError
+	if !errors.As(err, &e) || e.Op != "format" || e.Utility != "mkfs.nilfs2" {
+		t.Errorf("unexpected error %#v", err)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Block", "Format"); len(calls) != 0 {
+		t.Error("Format called although the utility is missing")
+	}
+}
+
+func TestFormatWithoutCanFormat(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1"})
+	// udisks before 2.7 has no CanFormat
+	srv.Handle("org.freedesktop.UDisks2.Manager", "CanFormat", nil)
+
+	if err := c.Format(blockDevice(t, c, p), "ext4", udisks.FormatOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Format(blockDevice(t, c, p), "nilfs2", udisks.FormatOptions{})
+	if !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("Format of a file system missing from SupportedFilesystems returned %v, want ErrNotSupported", err)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Block", "Format"); len(calls) != 1 {
+		t.Errorf("Format called %d times, want once", len(calls))
+	}
+}
@@ -0,0 +1,234 @@
+package udisks
+
+import (
+	"context"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Job is a long running operation of udisksd such as formatting with erase,
+// a secure erase or a RAID resync. It is exported below
+// /org/freedesktop/UDisks2/jobs while it runs.
+type Job struct {
+	Path string
+	// Operation is e.g. "format-mkfs", "ata-secure-erase" or "md-raid-sync"
+	Operation string
+	// Progress is between 0 and 1, it is only meaningful if ProgressValid is set
+	Progress      float64
+	ProgressValid bool
+	// Bytes is the amount of data the job processes, 0 if unknown
+	Bytes uint64
+	// Rate is in bytes per second, 0 if unknown
+	Rate            uint64
+	StartTime       time.Time
+	ExpectedEndTime time.Time
+	// Objects are the object paths of the drives and block devices affected
+	Objects      []string
+	StartedByUID uint32
+	Cancelable   bool
+
+	c *Client
+}
+
+// JobUpdate is delivered by Job.Watch. The last update has Done set and, when
+// the Completed signal was received, carries the result of the job.
+type JobUpdate struct {
+	Job     *Job
+	Done    bool
+	Success bool
+	Message string
+}
+
+func buildJob(c *Client, path dbus.ObjectPath, props map[string]dbus.Variant) *Job {
+	j := &Job{Path: string(path), Objects: []string{}, c: c}
+	prop(props, "Operation", &j.Operation)
+	prop(props, "Progress", &j.Progress)
+	prop(props, "ProgressValid", &j.ProgressValid)
+	prop(props, "Bytes", &j.Bytes)
+	prop(props, "Rate", &j.Rate)
+	timeProperty(props, "StartTime", &j.StartTime)
+	timeProperty(props, "ExpectedEndTime", &j.ExpectedEndTime)
+	prop(props, "StartedByUID", &j.StartedByUID)
+	prop(props, "Cancelable", &j.Cancelable)
+	var objects []dbus.ObjectPath
+	prop(props, "Objects", &objects)
+	for _, o := range objects {
+		j.Objects = append(j.Objects, string(o))
+	}
+	return j
+}
+
+// Remaining returns the estimated time until the job is done, or 0 if udisks
+// has no estimate
+func (j *Job) Remaining() time.Duration {
+	if j.ExpectedEndTime.IsZero() {
+		return 0
+	}
+	if d := time.Until(j.ExpectedEndTime); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Affects reports whether the object at path is one of the objects of the job
+func (j *Job) Affects(path string) bool {
+	for _, o := range j.Objects {
+		if o == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Cancel asks udisksd to abort the job. The job completes with Success unset.
+func (j *Job) Cancel() error {
+	return j.CancelContext(context.Background())
+}
+
+func (j *Job) CancelContext(ctx context.Context) error {
+	if j.c == nil {
+		return ErrNoClient
+	}
+	return j.c.call(ctx, dbus.ObjectPath(j.Path), "org.freedesktop.UDisks2.Job.Cancel", defaultOptions()).Err
+}
+
+// Watch delivers the current state of the job followed by an update for every
+// change until the job completes or ctx is cancelled. An error is returned if
+// the job has already finished.
+func (j *Job) Watch(ctx context.Context) (<-chan JobUpdate, error) {
+	if j.c == nil {
+		return nil, ErrNoClient
+	}
+	c := j.c
+	path := dbus.ObjectPath(j.Path)
+	sigs, unsubscribe, err := c.subscribe(ctx, [][]dbus.MatchOption{
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchObjectPath(path),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchObjectPath(path),
+			dbus.WithMatchInterface("org.freedesktop.UDisks2.Job"),
+			dbus.WithMatchMember("Completed"),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchInterface("org.freedesktop.DBus.ObjectManager"),
+			dbus.WithMatchMember("InterfacesRemoved"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var props map[string]dbus.Variant
+	err = c.call(ctx, path, "org.freedesktop.DBus.Properties.GetAll", "org.freedesktop.UDisks2.Job").Store(&props)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+
+	updates := make(chan JobUpdate)
+	go func() {
+		defer close(updates)
+		defer unsubscribe()
+		send := func(u JobUpdate) bool {
+			select {
+			case updates <- u:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !send(JobUpdate{Job: buildJob(c, path, props)}) {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-sigs:
+				if !ok {
+					return
+				}
+				switch sig.Name {
+				case "org.freedesktop.DBus.Properties.PropertiesChanged":
+					if sig.Path != path || len(sig.Body) != 3 {
+						continue
+					}
+					if iface, _ := sig.Body[0].(string); iface != "org.freedesktop.UDisks2.Job" {
+						continue
+					}
+					changed, _ := sig.Body[1].(map[string]dbus.Variant)
+					for name, v := range changed {
+						props[name] = v
+					}
+					if !send(JobUpdate{Job: buildJob(c, path, props)}) {
+						return
+					}
+				case "org.freedesktop.UDisks2.Job.Completed":
+					if sig.Path != path || len(sig.Body) != 2 {
+						continue
+					}
+					u := JobUpdate{Job: buildJob(c, path, props), Done: true}
+					u.Success, _ = sig.Body[0].(bool)
+					u.Message, _ = sig.Body[1].(string)
+					send(u)
+					return
+				case "org.freedesktop.DBus.ObjectManager.InterfacesRemoved":
+					// the job vanished without a Completed signal, e.g. udisksd exited
+					if len(sig.Body) != 2 {
+						continue
+					}
+					if removed, _ := sig.Body[0].(dbus.ObjectPath); removed != path {
+						continue
+					}
+					send(JobUpdate{Job: buildJob(c, path, props), Done: true})
+					return
+				}
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// JobsForObject returns the jobs affecting the drive or block device at path.
+// Use it after starting an operation with NoBlock to follow its progress.
+func (s *Snapshot) JobsForObject(path string) []*Job {
+	jobs := []*Job{}
+	for _, j := range s.Jobs {
+		if j.Affects(path) {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs
+}
+
+// Jobs returns the jobs currently running in udisksd
+func (c *Client) Jobs() ([]*Job, error) {
+	return c.JobsContext(context.Background())
+}
+
+func (c *Client) JobsContext(ctx context.Context) ([]*Job, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Jobs, nil
+}
+
+// JobsForObject returns the jobs affecting the drive or block device at path
+func (c *Client) JobsForObject(path string) ([]*Job, error) {
+	return c.JobsForObjectContext(context.Background(), path)
+}
+
+func (c *Client) JobsForObjectContext(ctx context.Context, path string) ([]*Job, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.JobsForObject(path), nil
+}
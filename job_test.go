@@ -0,0 +1,90 @@
+package udisks_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestJobsForObject(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb"})
+	srv.AddJob(udiskstest.Props{"Operation": "format-erase", "Objects": []dbus.ObjectPath{p}, "Bytes": uint64(1 << 30)})
+	srv.AddJob(udiskstest.Props{"Operation": "md-raid-sync"})
+
+	jobs, err := c.Jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("got %d jobs, want 2", len(jobs))
+	}
+	jobs, err = c.JobsForObject(string(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Operation != "format-erase" || jobs[0].Bytes != 1<<30 || !jobs[0].Cancelable {
+		t.Fatalf("unexpected jobs for %s: %+v", p, jobs)
+	}
+}
+
+func TestJobWatch(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb"})
+	path := srv.AddJob(udiskstest.Props{"Operation": "format-erase", "Objects": []dbus.ObjectPath{p}})
+	jobs, err := c.JobsForObject(string(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updates, err := jobs[0].Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := <-updates; u.Done || u.Job.ProgressValid {
+		t.Errorf("unexpected first update %+v", u)
+	}
+	srv.SetProperties(path, "org.freedesktop.UDisks2.Job", udiskstest.Props{"Progress": 0.5, "ProgressValid": true})
+	if u := <-updates; u.Done || u.Job.Progress != 0.5 || !u.Job.ProgressValid {
+		t.Errorf("unexpected progress update %+v", u.Job)
+	}
+	srv.CompleteJob(path, true, "")
+	if u := <-updates; !u.Done || !u.Success {
+		t.Errorf("unexpected last update %+v", u)
+	}
+	if _, ok := <-updates; ok {
+		t.Error("updates not closed after the job completed")
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb"})
+	path := srv.AddJob(udiskstest.Props{"Operation": "format-erase", "Objects": []dbus.ObjectPath{p}})
+	jobs, err := c.JobsForObject(string(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updates, err := jobs[0].Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-updates
+	if err := jobs[0].Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	if u := <-updates; !u.Done || u.Success || u.Message != "Operation was cancelled" {
+		t.Errorf("unexpected last update %+v", u)
+	}
+	if srv.HasObject(path) {
+		t.Error("job still exported after Cancel")
+	}
+}
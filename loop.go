@@ -0,0 +1,117 @@
+package udisks
+
+import (
+	"context"
+	"os"
+	"runtime"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Loop is the org.freedesktop.UDisks2.Loop interface of a loop device
+type Loop struct {
+	// BackingFile is the file the loop device is backed by
+	BackingFile string
+	// Autoclear makes the kernel delete the loop device when it is closed
+	Autoclear bool
+	// SetupByUID is the user that set up the loop device, 0 when unknown
+	SetupByUID uint32
+}
+
+// LoopOptions are the options of LoopSetup. Offset and Size are in bytes, a
+// Size of 0 uses the rest of the file.
+type LoopOptions struct {
+	Offset     uint64
+	Size       uint64
+	ReadOnly   bool
+	NoPartScan bool
+	// Interactive allows udisks to ask for authentication through polkit
+	Interactive bool
+}
+
+func (o LoopOptions) options() map[string]interface{} {
+	opt := map[string]interface{}{
+		"auth.no_user_interaction": !o.Interactive,
+	}
+	if o.Offset != 0 {
+		opt["offset"] = o.Offset
+	}
+	if o.Size != 0 {
+		opt["size"] = o.Size
+	}
+	if o.ReadOnly {
+		opt["read-only"] = true
+	}
+	if o.NoPartScan {
+		opt["no-part-scan"] = true
+	}
+	return opt
+}
+
+func buildLoop(props map[string]dbus.Variant) *Loop {
+	l := &Loop{}
+	byteStringProperty(props, "BackingFile", &l.BackingFile)
+	prop(props, "Autoclear", &l.Autoclear)
+	prop(props, "SetupByUID", &l.SetupByUID)
+	return l
+}
+
+// LoopSetup creates a loop device backed by the open file f and returns it.
+// The file descriptor is passed to udisksd, f can be closed afterwards.
+func (c *Client) LoopSetup(f *os.File, opts LoopOptions) (*BlockDevice, error) {
+	return c.LoopSetupContext(context.Background(), f, opts)
+}
+
+func (c *Client) LoopSetupContext(ctx context.Context, f *os.File, opts LoopOptions) (*BlockDevice, error) {
+	var path dbus.ObjectPath
+	err := c.call(ctx, "/org/freedesktop/UDisks2/Manager", "org.freedesktop.UDisks2.Manager.LoopSetup",
+		dbus.UnixFD(f.Fd()), opts.options()).Store(&path)
+	// f must not be finalized, closing the descriptor, before it was sent
+	runtime.KeepAlive(f)
+	if err != nil {
+		return nil, err
+	}
+	return c.blockDevice(ctx, path)
+}
+
+// LoopSetupPath opens the file at name, read-only if opts.ReadOnly is set, and
+// creates a loop device backed by it, see LoopSetup
+func (c *Client) LoopSetupPath(name string, opts LoopOptions) (*BlockDevice, error) {
+	return c.LoopSetupPathContext(context.Background(), name, opts)
+}
+
+func (c *Client) LoopSetupPathContext(ctx context.Context, name string, opts LoopOptions) (*BlockDevice, error) {
+	flag := os.O_RDWR
+	if opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(name, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.LoopSetupContext(ctx, f, opts)
+}
+
+// DeleteLoop detaches the loop device from its backing file
+func (c *Client) DeleteLoop(b *BlockDevice) error {
+	return c.DeleteLoopContext(context.Background(), b)
+}
+
+func (c *Client) DeleteLoopContext(ctx context.Context, b *BlockDevice) error {
+	return c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Loop.Delete", defaultOptions()).Err
+}
+
+// SetLoopAutoclear sets whether the loop device is deleted once it is no longer
+// used, e.g. after unmounting its file system
+func (c *Client) SetLoopAutoclear(b *BlockDevice, autoclear bool) error {
+	return c.SetLoopAutoclearContext(context.Background(), b, autoclear)
+}
+
+func (c *Client) SetLoopAutoclearContext(ctx context.Context, b *BlockDevice, autoclear bool) error {
+	err := c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Loop.SetAutoclear", autoclear, defaultOptions()).Err
+	if err == nil && b.Loop != nil {
+		b.Loop.Autoclear = autoclear
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package udisks_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestLoopSetup(t *testing.T) {
+	srv, c := newTestClient(t)
+	name := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(name, make([]byte, 1<<20), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := c.LoopSetupPath(name, udisks.LoopOptions{Offset: 4096, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Loop == nil || b.Loop.BackingFile != name {
+		t.Fatalf("unexpected loop properties %+v", b.Loop)
+	}
+	if b.Size != 1<<20-4096 || !b.ReadOnly {
+		t.Errorf("unexpected loop device %+v", b)
+	}
+	calls := srv.CallsTo("org.freedesktop.UDisks2.Manager", "LoopSetup")
+	if len(calls) != 1 {
+		t.Fatalf("got %d LoopSetup calls, want 1", len(calls))
+	}
+	opts := calls[0].Args[1].(map[string]dbus.Variant)
+	if opts["auth.no_user_interaction"].Value() != true || opts["offset"].Value() != uint64(4096) {
+		t.Errorf("unexpected LoopSetup options %v", opts)
+	}
+
+	if err := c.SetLoopAutoclear(b, true); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Loop.Autoclear {
+		t.Error("Autoclear not updated after SetLoopAutoclear")
+	}
+	if v, _ := srv.Property(dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Loop", "Autoclear"); v != true {
+		t.Errorf("Autoclear property %v, want true", v)
+	}
+
+	if err := c.DeleteLoop(b); err != nil {
+		t.Fatal(err)
+	}
+	if srv.HasObject(dbus.ObjectPath(b.Device)) {
+		t.Error("loop device still exported after DeleteLoop")
+	}
+}
+
+func TestDeleteLoopMounted(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{
+		Name:       "loop7",
+		IdUsage:    "filesystem",
+		Filesystem: udiskstest.Props{"MountPoints": mounted([]byte("/mnt"))},
+		Loop:       udiskstest.Props{"BackingFile": []byte("/tmp/disk.img\x00")},
+	})
+
+	if err := c.DeleteLoop(blockDevice(t, c, p)); !errors.Is(err, udisks.ErrDeviceBusy) {
+		t.Errorf("DeleteLoop returned %v, want ErrDeviceBusy", err)
+	}
+}
@@ -0,0 +1,338 @@
+package udisks
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Write policies of CreateVDOVolume
+const (
+	VDOWritePolicyAuto  = "auto"
+	VDOWritePolicySync  = "sync"
+	VDOWritePolicyAsync = "async"
+)
+
+// VolumeGroup is an LVM2 volume group. It is only exported while the lvm2
+// module of udisksd is loaded.
+type VolumeGroup struct {
+	Path     string
+	Name     string
+	UUID     string
+	Size     uint64
+	FreeSize uint64
+	// ExtentSize is the allocation unit, sizes of logical volumes are rounded
+	// up to it
+	ExtentSize uint64
+	// MissingPhysicalVolumes lists the UUIDs of physical volumes that are not
+	// present
+	MissingPhysicalVolumes []string
+}
+
+// LogicalVolume is an LVM2 logical volume
+type LogicalVolume struct {
+	Path string
+	Name string
+	UUID string
+	Size uint64
+	// Type is "block" for volumes that have a block device when active and
+	// "pool" for thin and VDO pools
+	Type string
+	// Layout is the LVM segment type, e.g. "linear", "thin", "thin-pool" or "vdo"
+	Layout string
+	Active bool
+	// DataAllocatedRatio and MetadataAllocatedRatio are the fill levels of thin
+	// pools and snapshots between 0 and 1
+	DataAllocatedRatio     float64
+	MetadataAllocatedRatio float64
+	// SyncRatio is the sync progress of RAID volumes between 0 and 1
+	SyncRatio   float64
+	VolumeGroup *VolumeGroup
+	// ThinPoolPath is the object path of the pool of a thin volume, OriginPath
+	// the volume a snapshot was taken of and BlockDevicePath the block device
+	// of an active volume
+	ThinPoolPath    string
+	OriginPath      string
+	BlockDevicePath string
+}
+
+// PhysicalVolume is the org.freedesktop.UDisks2.PhysicalVolume interface of a
+// block device used by a volume group
+type PhysicalVolume struct {
+	VolumeGroup *VolumeGroup
+	Size        uint64
+	FreeSize    uint64
+}
+
+// VDOOptions are the parameters of CreateVDOVolume
+type VDOOptions struct {
+	// PoolName is the name of the VDO pool volume holding the data
+	PoolName string
+	// DataSize is the size of the pool in the volume group, VirtualSize the
+	// size of the volume presented to users
+	DataSize    uint64
+	VirtualSize uint64
+	// IndexMemory is the memory used by the deduplication index, 0 for the
+	// default
+	IndexMemory   uint64
+	Compression   bool
+	Deduplication bool
+	// WritePolicy is one of the VDOWritePolicy constants, VDOWritePolicyAuto
+	// when empty
+	WritePolicy string
+}
+
+func buildVolumeGroup(path dbus.ObjectPath, props map[string]dbus.Variant) *VolumeGroup {
+	vg := &VolumeGroup{Path: string(path), MissingPhysicalVolumes: []string{}}
+	prop(props, "Name", &vg.Name)
+	prop(props, "UUID", &vg.UUID)
+	prop(props, "Size", &vg.Size)
+	prop(props, "FreeSize", &vg.FreeSize)
+	prop(props, "ExtentSize", &vg.ExtentSize)
+	prop(props, "MissingPhysicalVolumes", &vg.MissingPhysicalVolumes)
+	return vg
+}
+
+func buildLogicalVolume(path dbus.ObjectPath, props map[string]dbus.Variant) *LogicalVolume {
+	lv := &LogicalVolume{Path: string(path)}
+	prop(props, "Name", &lv.Name)
+	prop(props, "UUID", &lv.UUID)
+	prop(props, "Size", &lv.Size)
+	prop(props, "Type", &lv.Type)
+	prop(props, "Layout", &lv.Layout)
+	prop(props, "Active", &lv.Active)
+	prop(props, "DataAllocatedRatio", &lv.DataAllocatedRatio)
+	prop(props, "MetadataAllocatedRatio", &lv.MetadataAllocatedRatio)
+	prop(props, "SyncRatio", &lv.SyncRatio)
+	lv.ThinPoolPath = string(objectPathProperty(props, "ThinPool"))
+	lv.OriginPath = string(objectPathProperty(props, "Origin"))
+	lv.BlockDevicePath = string(objectPathProperty(props, "BlockDevice"))
+	return lv
+}
+
+func buildPhysicalVolume(props map[string]dbus.Variant) *PhysicalVolume {
+	pv := &PhysicalVolume{}
+	prop(props, "Size", &pv.Size)
+	prop(props, "FreeSize", &pv.FreeSize)
+	return pv
+}
+
+// VolumeGroups returns the LVM2 volume groups known to udisks
+func (c *Client) VolumeGroups() ([]*VolumeGroup, error) {
+	return c.VolumeGroupsContext(context.Background())
+}
+
+func (c *Client) VolumeGroupsContext(ctx context.Context) ([]*VolumeGroup, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.VolumeGroups, nil
+}
+
+// LogicalVolumes returns the logical volumes of all volume groups
+func (c *Client) LogicalVolumes() ([]*LogicalVolume, error) {
+	return c.LogicalVolumesContext(context.Background())
+}
+
+func (c *Client) LogicalVolumesContext(ctx context.Context) ([]*LogicalVolume, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.LogicalVolumes, nil
+}
+
+// logicalVolume returns the logical volume at path from a fresh snapshot
+func (c *Client) logicalVolume(ctx context.Context, path dbus.ObjectPath) (*LogicalVolume, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, lv := range s.LogicalVolumes {
+		if lv.Path == string(path) {
+			return lv, nil
+		}
+	}
+	return &LogicalVolume{Path: string(path)}, nil
+}
+
+func (c *Client) createVolume(ctx context.Context, vg *VolumeGroup, method string, args ...interface{}) (*LogicalVolume, error) {
+	var path dbus.ObjectPath
+	err := c.call(ctx, dbus.ObjectPath(vg.Path), "org.freedesktop.UDisks2.VolumeGroup."+method, append(args, defaultOptions())...).Store(&path)
+	if err != nil {
+		return nil, err
+	}
+	return c.logicalVolume(ctx, path)
+}
+
+// CreatePlainVolume creates a linear logical volume of size bytes in the
+// volume group
+func (c *Client) CreatePlainVolume(vg *VolumeGroup, name string, size uint64) (*LogicalVolume, error) {
+	return c.CreatePlainVolumeContext(context.Background(), vg, name, size)
+}
+
+func (c *Client) CreatePlainVolumeContext(ctx context.Context, vg *VolumeGroup, name string, size uint64) (*LogicalVolume, error) {
+	return c.createVolume(ctx, vg, "CreatePlainVolume", name, size)
+}
+
+// CreateThinPoolVolume creates a thin pool of size bytes, thin volumes are
+// created in it with CreateThinVolume
+func (c *Client) CreateThinPoolVolume(vg *VolumeGroup, name string, size uint64) (*LogicalVolume, error) {
+	return c.CreateThinPoolVolumeContext(context.Background(), vg, name, size)
+}
+
+func (c *Client) CreateThinPoolVolumeContext(ctx context.Context, vg *VolumeGroup, name string, size uint64) (*LogicalVolume, error) {
+	return c.createVolume(ctx, vg, "CreateThinPoolVolume", name, size)
+}
+
+// CreateThinVolume creates a thinly provisioned volume of virtual size bytes in
+// pool
+func (c *Client) CreateThinVolume(vg *VolumeGroup, name string, size uint64, pool *LogicalVolume) (*LogicalVolume, error) {
+	return c.CreateThinVolumeContext(context.Background(), vg, name, size, pool)
+}
+
+func (c *Client) CreateThinVolumeContext(ctx context.Context, vg *VolumeGroup, name string, size uint64, pool *LogicalVolume) (*LogicalVolume, error) {
+	return c.createVolume(ctx, vg, "CreateThinVolume", name, size, dbus.ObjectPath(pool.Path))
+}
+
+// CreateVDOVolume creates a compressing and deduplicating VDO volume together
+// with the pool holding its data
+func (c *Client) CreateVDOVolume(vg *VolumeGroup, name string, opts VDOOptions) (*LogicalVolume, error) {
+	return c.CreateVDOVolumeContext(context.Background(), vg, name, opts)
+}
+
+func (c *Client) CreateVDOVolumeContext(ctx context.Context, vg *VolumeGroup, name string, opts VDOOptions) (*LogicalVolume, error) {
+	policy := opts.WritePolicy
+	if policy == "" {
+		policy = VDOWritePolicyAuto
+	}
+	return c.createVolume(ctx, vg, "CreateVDOVolume", name, opts.PoolName, opts.DataSize, opts.VirtualSize,
+		opts.IndexMemory, opts.Compression, opts.Deduplication, policy)
+}
+
+// ResizeLogicalVolume changes the size of the volume. With resizeFilesystem
+// the file system on it is grown or shrunk along with it, and as with Resize
+// the default timeout of the client is not applied.
+func (c *Client) ResizeLogicalVolume(lv *LogicalVolume, size uint64, resizeFilesystem bool) error {
+	return c.ResizeLogicalVolumeContext(context.Background(), lv, size, resizeFilesystem)
+}
+
+func (c *Client) ResizeLogicalVolumeContext(ctx context.Context, lv *LogicalVolume, size uint64, resizeFilesystem bool) error {
+	opt := defaultOptions()
+	if resizeFilesystem {
+		opt["resize_fsys"] = true
+	}
+	call := c.call
+	if resizeFilesystem {
+		call = c.callNoDefaultTimeout
+	}
+	err := call(ctx, dbus.ObjectPath(lv.Path), "org.freedesktop.UDisks2.LogicalVolume.Resize", size, opt).Err
+	if err == nil {
+		lv.Size = size
+	}
+	return err
+}
+
+// RenameLogicalVolume renames the volume. Its object path changes, lv is
+// updated to the new one.
+func (c *Client) RenameLogicalVolume(lv *LogicalVolume, name string) error {
+	return c.RenameLogicalVolumeContext(context.Background(), lv, name)
+}
+
+func (c *Client) RenameLogicalVolumeContext(ctx context.Context, lv *LogicalVolume, name string) error {
+	var path dbus.ObjectPath
+	err := c.call(ctx, dbus.ObjectPath(lv.Path), "org.freedesktop.UDisks2.LogicalVolume.Rename", name, defaultOptions()).Store(&path)
+	if err != nil {
+		return err
+	}
+	lv.Name = name
+	lv.Path = string(path)
+	return nil
+}
+
+// ActivateLogicalVolume activates the volume and returns its block device
+func (c *Client) ActivateLogicalVolume(lv *LogicalVolume) (*BlockDevice, error) {
+	return c.ActivateLogicalVolumeContext(context.Background(), lv)
+}
+
+func (c *Client) ActivateLogicalVolumeContext(ctx context.Context, lv *LogicalVolume) (*BlockDevice, error) {
+	var path dbus.ObjectPath
+	err := c.call(ctx, dbus.ObjectPath(lv.Path), "org.freedesktop.UDisks2.LogicalVolume.Activate", defaultOptions()).Store(&path)
+	if err != nil {
+		return nil, err
+	}
+	lv.Active = true
+	lv.BlockDevicePath = string(path)
+	return c.blockDevice(ctx, path)
+}
+
+// DeactivateLogicalVolume deactivates the volume, removing its block device
+func (c *Client) DeactivateLogicalVolume(lv *LogicalVolume) error {
+	return c.DeactivateLogicalVolumeContext(context.Background(), lv)
+}
+
+func (c *Client) DeactivateLogicalVolumeContext(ctx context.Context, lv *LogicalVolume) error {
+	err := c.call(ctx, dbus.ObjectPath(lv.Path), "org.freedesktop.UDisks2.LogicalVolume.Deactivate", defaultOptions()).Err
+	if err == nil {
+		lv.Active = false
+		lv.BlockDevicePath = ""
+	}
+	return err
+}
+
+// CreateLogicalVolumeSnapshot creates a snapshot of the volume and returns it.
+// size is the space reserved for changes, 0 creates a thin snapshot of a thin
+// volume.
+func (c *Client) CreateLogicalVolumeSnapshot(lv *LogicalVolume, name string, size uint64) (*LogicalVolume, error) {
+	return c.CreateLogicalVolumeSnapshotContext(context.Background(), lv, name, size)
+}
+
+func (c *Client) CreateLogicalVolumeSnapshotContext(ctx context.Context, lv *LogicalVolume, name string, size uint64) (*LogicalVolume, error) {
+	var path dbus.ObjectPath
+	err := c.call(ctx, dbus.ObjectPath(lv.Path), "org.freedesktop.UDisks2.LogicalVolume.CreateSnapshot", name, size, defaultOptions()).Store(&path)
+	if err != nil {
+		return nil, err
+	}
+	return c.logicalVolume(ctx, path)
+}
+
+// DeleteLogicalVolume removes the volume and the data on it
+func (c *Client) DeleteLogicalVolume(lv *LogicalVolume) error {
+	return c.DeleteLogicalVolumeContext(context.Background(), lv)
+}
+
+func (c *Client) DeleteLogicalVolumeContext(ctx context.Context, lv *LogicalVolume) error {
+	return c.call(ctx, dbus.ObjectPath(lv.Path), "org.freedesktop.UDisks2.LogicalVolume.Delete", defaultOptions()).Err
+}
+
+// AddVolumeGroupDevice initializes the block device as physical volume and adds
+// it to the volume group
+func (c *Client) AddVolumeGroupDevice(vg *VolumeGroup, b *BlockDevice) error {
+	return c.AddVolumeGroupDeviceContext(context.Background(), vg, b)
+}
+
+func (c *Client) AddVolumeGroupDeviceContext(ctx context.Context, vg *VolumeGroup, b *BlockDevice) error {
+	return c.call(ctx, dbus.ObjectPath(vg.Path), "org.freedesktop.UDisks2.VolumeGroup.AddDevice", dbus.ObjectPath(b.Device), defaultOptions()).Err
+}
+
+// RemoveVolumeGroupDevice removes an unused physical volume from the volume
+// group. With wipe the LVM2 signature on the device is erased afterwards.
+func (c *Client) RemoveVolumeGroupDevice(vg *VolumeGroup, b *BlockDevice, wipe bool) error {
+	return c.RemoveVolumeGroupDeviceContext(context.Background(), vg, b, wipe)
+}
+
+func (c *Client) RemoveVolumeGroupDeviceContext(ctx context.Context, vg *VolumeGroup, b *BlockDevice, wipe bool) error {
+	return c.call(ctx, dbus.ObjectPath(vg.Path), "org.freedesktop.UDisks2.VolumeGroup.RemoveDevice", dbus.ObjectPath(b.Device), wipe, defaultOptions()).Err
+}
+
+// EmptyVolumeGroupDevice moves all data off the physical volume to the other
+// physical volumes of the group so it can be removed. Copying the data can take
+// hours, so the default timeout of the client is not applied.
+func (c *Client) EmptyVolumeGroupDevice(vg *VolumeGroup, b *BlockDevice) error {
+	return c.EmptyVolumeGroupDeviceContext(context.Background(), vg, b)
+}
+
+func (c *Client) EmptyVolumeGroupDeviceContext(ctx context.Context, vg *VolumeGroup, b *BlockDevice) error {
+	return c.callNoDefaultTimeout(ctx, dbus.ObjectPath(vg.Path), "org.freedesktop.UDisks2.VolumeGroup.EmptyDevice", dbus.ObjectPath(b.Device), defaultOptions()).Err
+}
@@ -0,0 +1,189 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+// volumeGroup returns the volume group at path from a fresh snapshot
+func volumeGroup(t *testing.T, c *udisks.Client, path dbus.ObjectPath) *udisks.VolumeGroup {
+	t.Helper()
+	vgs, err := c.VolumeGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vg := range vgs {
+		if vg.Path == string(path) {
+			return vg
+		}
+	}
+	t.Fatalf("volume group %s not found", path)
+	return nil
+}
+
+// newVolumeGroup exports the volume group "vg" on two 1 GiB physical volumes
+func newVolumeGroup(srv *udiskstest.Server) dbus.ObjectPath {
+	sdb := srv.AddBlock(udiskstest.Block{Name: "sdb", Size: 1 << 30})
+	sdc := srv.AddBlock(udiskstest.Block{Name: "sdc", Size: 1 << 30})
+	return srv.AddVolumeGroup(udiskstest.VolumeGroup{Name: "vg", Members: []dbus.ObjectPath{sdb, sdc}})
+}
+
+func TestCreateVolumes(t *testing.T) {
+	srv, c := newTestClient(t)
+	vg := volumeGroup(t, c, newVolumeGroup(srv))
+
+	lv, err := c.CreatePlainVolume(vg, "data", 512<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lv.Name != "data" || lv.Size != 512<<20 || lv.Layout != "linear" || lv.VolumeGroup == nil || lv.VolumeGroup.Name != "vg" {
+		t.Errorf("unexpected logical volume %+v", lv)
+	}
+	if got := volumeGroup(t, c, dbus.ObjectPath(vg.Path)); got.FreeSize != got.Size-512<<20 {
+		t.Errorf("free size %d after CreatePlainVolume, want %d", got.FreeSize, got.Size-512<<20)
+	}
+
+	pool, err := c.CreateThinPoolVolume(vg, "pool", 256<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thin, err := c.CreateThinVolume(vg, "thin", 4<<30, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thin.Layout != "thin" || thin.ThinPoolPath != pool.Path || thin.Size != 4<<30 {
+		t.Errorf("unexpected thin volume %+v", thin)
+	}
+	if _, err := c.CreateThinVolume(vg, "thin2", 1<<30, lv); !errors.Is(err, udisks.ErrFailed) {
+		t.Errorf("CreateThinVolume in a linear volume returned %v, want ErrFailed", err)
+	}
+
+	vdo, err := c.CreateVDOVolume(vg, "vdo", udisks.VDOOptions{PoolName: "vdopool", DataSize: 256 << 20, VirtualSize: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vdo.Layout != "vdo" || vdo.Size != 1<<30 {
+		t.Errorf("unexpected VDO volume %+v", vdo)
+	}
+	calls := srv.CallsTo("org.freedesktop.UDisks2.VolumeGroup", "CreateVDOVolume")
+	if policy := calls[0].Args[7]; policy != udisks.VDOWritePolicyAuto {
+		t.Errorf("write policy %v, want auto", policy)
+	}
+}
+
+func TestLogicalVolumeLifecycle(t *testing.T) {
+	srv, c := newTestClient(t)
+	vgPath := newVolumeGroup(srv)
+	srv.AddLogicalVolume(udiskstest.LogicalVolume{VolumeGroup: vgPath, Name: "data", Size: 256 << 20})
+	lvs, err := c.LogicalVolumes()
+	if err != nil || len(lvs) != 1 {
+		t.Fatalf("LogicalVolumes returned %v, %v", lvs, err)
+	}
+	lv := lvs[0]
+
+	b, err := c.ActivateLogicalVolume(lv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lv.Active || lv.BlockDevicePath != b.Device || b.Size != 256<<20 {
+		t.Errorf("unexpected volume %+v and block device %+v after ActivateLogicalVolume", lv, b)
+	}
+
+	if err := c.ResizeLogicalVolume(lv, 512<<20, false); err != nil {
+		t.Fatal(err)
+	}
+	if lv.Size != 512<<20 || blockDevice(t, c, dbus.ObjectPath(b.Device)).Size != 512<<20 {
+		t.Errorf("size %d after ResizeLogicalVolume, want %d", lv.Size, 512<<20)
+	}
+
+	if err := c.RenameLogicalVolume(lv, "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if lv.Name != "renamed" || lv.Path != string(udiskstest.LogicalVolumePath("vg", "renamed")) || !srv.HasObject(dbus.ObjectPath(lv.Path)) {
+		t.Errorf("unexpected volume after RenameLogicalVolume %+v", lv)
+	}
+
+	snap, err := c.CreateLogicalVolumeSnapshot(lv, "snap", 64<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.OriginPath != lv.Path || snap.Layout != "snapshot" {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+
+	if err := c.DeactivateLogicalVolume(lv); err != nil {
+		t.Fatal(err)
+	}
+	if lv.Active || lv.BlockDevicePath != "" || srv.HasObject(dbus.ObjectPath(b.Device)) {
+		t.Error("volume still active after DeactivateLogicalVolume")
+	}
+	if err := c.DeleteLogicalVolume(lv); err != nil {
+		t.Fatal(err)
+	}
+	if srv.HasObject(dbus.ObjectPath(lv.Path)) {
+		t.Error("volume still exported after DeleteLogicalVolume")
+	}
+}
+
+func TestResizeLogicalVolumeFilesystem(t *testing.T) {
+	srv, c := newTestClient(t)
+	vgPath := newVolumeGroup(srv)
+	srv.AddLogicalVolume(udiskstest.LogicalVolume{VolumeGroup: vgPath, Name: "data", Size: 256 << 20, Active: true})
+	lvs, err := c.LogicalVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := dbus.ObjectPath(lvs[0].BlockDevicePath)
+	srv.AddObject(b, map[string]udiskstest.Props{"org.freedesktop.UDisks2.Filesystem": {"Size": uint64(256 << 20)}})
+
+	if err := c.ResizeLogicalVolume(lvs[0], 512<<20, true); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := srv.Property(b, "org.freedesktop.UDisks2.Filesystem", "Size"); v != uint64(512<<20) {
+		t.Errorf("file system size %v after ResizeLogicalVolume, want %d", v, 512<<20)
+	}
+	opts := srv.CallsTo("org.freedesktop.UDisks2.LogicalVolume", "Resize")[0].Args[1].(map[string]dbus.Variant)
+	if opts["resize_fsys"].Value() != true || opts["auth.no_user_interaction"].Value() != true {
+		t.Errorf("unexpected Resize options %v", opts)
+	}
+}
+
+func TestVolumeGroupDevices(t *testing.T) {
+	srv, c := newTestClient(t)
+	vgPath := newVolumeGroup(srv)
+	srv.AddLogicalVolume(udiskstest.LogicalVolume{VolumeGroup: vgPath, Name: "data", Size: 256 << 20})
+	vg := volumeGroup(t, c, vgPath)
+	sdd := srv.AddBlock(udiskstest.Block{Name: "sdd", Size: 1 << 30})
+
+	if err := c.AddVolumeGroupDevice(vg, blockDevice(t, c, sdd)); err != nil {
+		t.Fatal(err)
+	}
+	if got := volumeGroup(t, c, vgPath); got.Size != 3<<30 {
+		t.Errorf("volume group size %d after AddVolumeGroupDevice, want %d", got.Size, 3<<30)
+	}
+	if b := blockDevice(t, c, sdd); b.PhysicalVolume == nil || b.PhysicalVolume.VolumeGroup == nil || b.PhysicalVolume.VolumeGroup.Name != "vg" {
+		t.Errorf("sdd is not a physical volume of vg: %+v", b.PhysicalVolume)
+	}
+
+	// the volume was allocated on sdb, which must be emptied before removal
+	sdb := blockDevice(t, c, udiskstest.BlockPath("sdb"))
+	if err := c.RemoveVolumeGroupDevice(vg, sdb, true); !errors.Is(err, udisks.ErrFailed) {
+		t.Fatalf("RemoveVolumeGroupDevice of a used device returned %v, want ErrFailed", err)
+	}
+	if err := c.EmptyVolumeGroupDevice(vg, sdb); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveVolumeGroupDevice(vg, sdb, true); err != nil {
+		t.Fatal(err)
+	}
+	if b := blockDevice(t, c, udiskstest.BlockPath("sdb")); b.PhysicalVolume != nil || b.IdUsage != "" {
+		t.Errorf("sdb not removed and wiped: %+v", b)
+	}
+	if got := volumeGroup(t, c, vgPath); got.Size != 2<<30 || got.FreeSize != 2<<30-256<<20 {
+		t.Errorf("unexpected volume group after RemoveVolumeGroupDevice %+v", got)
+	}
+}
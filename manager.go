@@ -0,0 +1,20 @@
+package udisks
+
+import "github.com/godbus/dbus/v5"
+
+// Manager holds the properties of the org.freedesktop.UDisks2.Manager object
+type Manager struct {
+	Version                  string
+	SupportedFilesystems     []string
+	SupportedEncryptionTypes []string
+	DefaultEncryptionType    string
+}
+
+func buildManager(props map[string]dbus.Variant) *Manager {
+	m := &Manager{}
+	prop(props, "Version", &m.Version)
+	prop(props, "SupportedFilesystems", &m.SupportedFilesystems)
+	prop(props, "SupportedEncryptionTypes", &m.SupportedEncryptionTypes)
+	prop(props, "DefaultEncryptionType", &m.DefaultEncryptionType)
+	return m
+}
@@ -0,0 +1,228 @@
+package udisks
+
+import (
+	"context"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Sync actions of RequestMDRaidSyncAction
+const (
+	MDRaidSyncCheck  = "check"
+	MDRaidSyncRepair = "repair"
+	MDRaidSyncIdle   = "idle"
+)
+
+// MDRaid is a Linux software RAID array
+type MDRaid struct {
+	Path string
+	UUID string
+	Name string
+	// Level is e.g. "raid1" or "raid5"
+	Level      string
+	NumDevices uint32
+	Size       uint64
+	Running    bool
+	// SyncAction is the running sync action, e.g. "idle", "resync" or "check"
+	SyncAction string
+	// SyncCompleted is between 0 and 1
+	SyncCompleted float64
+	// SyncRate is in bytes per second
+	SyncRate          uint64
+	SyncRemainingTime time.Duration
+	// Degraded is the number of missing devices
+	Degraded uint32
+	// BitmapLocation is "internal", "none" or a file
+	BitmapLocation string
+	ChunkSize      uint64
+	ActiveDevices  []MDRaidDevice
+}
+
+// MDRaidDevice is a member of a running array
+type MDRaidDevice struct {
+	// Block is the object path of the block device
+	Block string
+	// Slot is the position in the array, -1 if the device is not active
+	Slot int32
+	// State is the state reported by the kernel, e.g. "in_sync" or "faulty"
+	State         []string
+	NumReadErrors uint64
+}
+
+func buildMDRaid(path dbus.ObjectPath, props map[string]dbus.Variant) *MDRaid {
+	m := &MDRaid{Path: string(path), ActiveDevices: []MDRaidDevice{}}
+	prop(props, "UUID", &m.UUID)
+	prop(props, "Name", &m.Name)
+	prop(props, "Level", &m.Level)
+	prop(props, "NumDevices", &m.NumDevices)
+	prop(props, "Size", &m.Size)
+	prop(props, "Running", &m.Running)
+	prop(props, "SyncAction", &m.SyncAction)
+	prop(props, "SyncCompleted", &m.SyncCompleted)
+	prop(props, "SyncRate", &m.SyncRate)
+	var remaining uint64
+	prop(props, "SyncRemainingTime", &remaining)
+	m.SyncRemainingTime = time.Duration(remaining) * time.Microsecond
+	prop(props, "Degraded", &m.Degraded)
+	byteStringProperty(props, "BitmapLocation", &m.BitmapLocation)
+	prop(props, "ChunkSize", &m.ChunkSize)
+	var devices []struct {
+		Block         dbus.ObjectPath
+		Slot          int32
+		State         []string
+		NumReadErrors uint64
+		Expansion     map[string]dbus.Variant
+	}
+	storeProperty(props, "ActiveDevices", &devices)
+	for _, d := range devices {
+		m.ActiveDevices = append(m.ActiveDevices, MDRaidDevice{
+			Block:         string(d.Block),
+			Slot:          d.Slot,
+			State:         d.State,
+			NumReadErrors: d.NumReadErrors,
+		})
+	}
+	return m
+}
+
+// MDRaids returns the RAID arrays known to udisks, including arrays that are
+// not running
+func (c *Client) MDRaids() ([]*MDRaid, error) {
+	return c.MDRaidsContext(context.Background())
+}
+
+func (c *Client) MDRaidsContext(ctx context.Context) ([]*MDRaid, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.MDRaids, nil
+}
+
+// MDRaidCreate creates a RAID array of level from the block devices and returns
+// it. chunk is the chunk size in bytes, 0 for the default.
+func (c *Client) MDRaidCreate(blocks []*BlockDevice, level, name string, chunk uint64) (*MDRaid, error) {
+	return c.MDRaidCreateContext(context.Background(), blocks, level, name, chunk)
+}
+
+func (c *Client) MDRaidCreateContext(ctx context.Context, blocks []*BlockDevice, level, name string, chunk uint64) (*MDRaid, error) {
+	paths := make([]dbus.ObjectPath, 0, len(blocks))
+	for _, b := range blocks {
+		paths = append(paths, dbus.ObjectPath(b.Device))
+	}
+	var path dbus.ObjectPath
+	err := c.call(ctx, "/org/freedesktop/UDisks2/Manager", "org.freedesktop.UDisks2.Manager.MDRaidCreate",
+		paths, level, name, chunk, defaultOptions()).Store(&path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range s.MDRaids {
+		if m.Path == string(path) {
+			return m, nil
+		}
+	}
+	return &MDRaid{Path: string(path)}, nil
+}
+
+// StartMDRaid assembles and starts the array. With degraded the array is
+// started even if members are missing.
+func (c *Client) StartMDRaid(m *MDRaid, degraded bool) error {
+	return c.StartMDRaidContext(context.Background(), m, degraded)
+}
+
+func (c *Client) StartMDRaidContext(ctx context.Context, m *MDRaid, degraded bool) error {
+	opt := defaultOptions()
+	if degraded {
+		opt["start-degraded"] = true
+	}
+	err := c.call(ctx, dbus.ObjectPath(m.Path), "org.freedesktop.UDisks2.MDRaid.Start", opt).Err
+	if err == nil {
+		m.Running = true
+	}
+	return err
+}
+
+// StopMDRaid stops the array. Its members are left intact, so it can be started
+// again with StartMDRaid.
+func (c *Client) StopMDRaid(m *MDRaid) error {
+	return c.StopMDRaidContext(context.Background(), m)
+}
+
+func (c *Client) StopMDRaidContext(ctx context.Context, m *MDRaid) error {
+	err := c.mdraidCall(ctx, m, "Stop")
+	if err == nil {
+		m.Running = false
+	}
+	return err
+}
+
+// AddMDRaidDevice adds the block device to the array as a spare or to replace a
+// missing member
+func (c *Client) AddMDRaidDevice(m *MDRaid, b *BlockDevice) error {
+	return c.AddMDRaidDeviceContext(context.Background(), m, b)
+}
+
+func (c *Client) AddMDRaidDeviceContext(ctx context.Context, m *MDRaid, b *BlockDevice) error {
+	return c.mdraidCall(ctx, m, "AddDevice", dbus.ObjectPath(b.Device))
+}
+
+// RemoveMDRaidDevice marks the member as faulty and removes it from the array.
+// With wipe the RAID signature on the device is erased afterwards.
+func (c *Client) RemoveMDRaidDevice(m *MDRaid, b *BlockDevice, wipe bool) error {
+	return c.RemoveMDRaidDeviceContext(context.Background(), m, b, wipe)
+}
+
+func (c *Client) RemoveMDRaidDeviceContext(ctx context.Context, m *MDRaid, b *BlockDevice, wipe bool) error {
+	opt := defaultOptions()
+	if wipe {
+		opt["wipe"] = true
+	}
+	return c.call(ctx, dbus.ObjectPath(m.Path), "org.freedesktop.UDisks2.MDRaid.RemoveDevice", dbus.ObjectPath(b.Device), opt).Err
+}
+
+// SetMDRaidBitmapLocation sets the write-intent bitmap to "internal" or "none"
+func (c *Client) SetMDRaidBitmapLocation(m *MDRaid, location string) error {
+	return c.SetMDRaidBitmapLocationContext(context.Background(), m, location)
+}
+
+func (c *Client) SetMDRaidBitmapLocationContext(ctx context.Context, m *MDRaid, location string) error {
+	err := c.mdraidCall(ctx, m, "SetBitmapLocation", append([]byte(location), 0))
+	if err == nil {
+		m.BitmapLocation = location
+	}
+	return err
+}
+
+// RequestMDRaidSyncAction starts MDRaidSyncCheck or MDRaidSyncRepair on the
+// array, MDRaidSyncIdle stops the running action
+func (c *Client) RequestMDRaidSyncAction(m *MDRaid, action string) error {
+	return c.RequestMDRaidSyncActionContext(context.Background(), m, action)
+}
+
+func (c *Client) RequestMDRaidSyncActionContext(ctx context.Context, m *MDRaid, action string) error {
+	return c.mdraidCall(ctx, m, "RequestSyncAction", action)
+}
+
+// DeleteMDRaid stops the array and wipes the RAID signatures of its members.
+// With tearDown the file systems, encrypted containers and configuration on it
+// are removed first.
+func (c *Client) DeleteMDRaid(m *MDRaid, tearDown bool) error {
+	return c.DeleteMDRaidContext(context.Background(), m, tearDown)
+}
+
+func (c *Client) DeleteMDRaidContext(ctx context.Context, m *MDRaid, tearDown bool) error {
+	opt := defaultOptions()
+	if tearDown {
+		opt["tear-down"] = true
+	}
+	return c.call(ctx, dbus.ObjectPath(m.Path), "org.freedesktop.UDisks2.MDRaid.Delete", opt).Err
+}
+
+func (c *Client) mdraidCall(ctx context.Context, m *MDRaid, method string, args ...interface{}) error {
+	return c.call(ctx, dbus.ObjectPath(m.Path), "org.freedesktop.UDisks2.MDRaid."+method, append(args, defaultOptions())...).Err
+}
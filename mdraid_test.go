@@ -0,0 +1,161 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+// mdraid returns the RAID array at path from a fresh snapshot
+func mdraid(t *testing.T, c *udisks.Client, path dbus.ObjectPath) *udisks.MDRaid {
+	t.Helper()
+	raids, err := c.MDRaids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range raids {
+		if m.Path == string(path) {
+			return m
+		}
+	}
+	t.Fatalf("RAID array %s not found", path)
+	return nil
+}
+
+func TestMDRaidCreate(t *testing.T) {
+	srv, c := newTestClient(t)
+	sdb := srv.AddBlock(udiskstest.Block{Name: "sdb", Size: 1 << 30})
+	sdc := srv.AddBlock(udiskstest.Block{Name: "sdc", Size: 2 << 30})
+	sdd := srv.AddBlock(udiskstest.Block{Name: "sdd", Size: 1 << 30})
+	blocks := []*udisks.BlockDevice{blockDevice(t, c, sdb), blockDevice(t, c, sdc), blockDevice(t, c, sdd)}
+
+	m, err := c.MDRaidCreate(blocks, "raid5", "data", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "data" || m.Level != "raid5" || m.Size != 2<<30 || !m.Running || m.NumDevices != 3 {
+		t.Errorf("unexpected RAID array %+v", m)
+	}
+	if len(m.ActiveDevices) != 3 || m.Degraded != 0 {
+		t.Errorf("unexpected active devices %+v", m.ActiveDevices)
+	}
+	if b := blockDevice(t, c, sdb); b.MDRaidMember != m.Path {
+		t.Errorf("sdb is not a member of %s: %+v", m.Path, b)
+	}
+
+	if _, err := c.MDRaidCreate(blocks[:1], "raid1", "", 0); !errors.Is(err, udisks.ErrFailed) {
+		t.Errorf("MDRaidCreate with one device returned %v, want ErrFailed", err)
+	}
+}
+
+func TestStartStopMDRaid(t *testing.T) {
+	srv, c := newTestClient(t)
+	sdb := srv.AddBlock(udiskstest.Block{Name: "sdb", Size: 1 << 30})
+	p := srv.AddMDRaid(udiskstest.MDRaid{UUID: "1", Level: "raid1", Members: []dbus.ObjectPath{sdb}, Size: 1 << 30, Props: udiskstest.Props{"NumDevices": uint32(2)}})
+	m := mdraid(t, c, p)
+
+	if err := c.StartMDRaid(m, false); !errors.Is(err, udisks.ErrFailed) {
+		t.Fatalf("StartMDRaid without all members returned %v, want ErrFailed", err)
+	}
+	if err := c.StartMDRaid(m, true); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Running {
+		t.Error("Running not updated after StartMDRaid")
+	}
+	if got := mdraid(t, c, p); !got.Running || got.Degraded != 1 {
+		t.Errorf("unexpected RAID array after StartMDRaid %+v", got)
+	}
+	calls := srv.CallsTo("org.freedesktop.UDisks2.MDRaid", "Start")
+	if opts := calls[len(calls)-1].Args[0].(map[string]dbus.Variant); opts["start-degraded"].Value() != true {
+		t.Errorf("StartMDRaid options %v, want start-degraded", opts)
+	}
+
+	if err := c.StopMDRaid(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Running || mdraid(t, c, p).Running {
+		t.Error("RAID array still running after StopMDRaid")
+	}
+}
+
+func TestMDRaidDevices(t *testing.T) {
+	srv, c := newTestClient(t)
+	sdb := srv.AddBlock(udiskstest.Block{Name: "sdb", Size: 1 << 30})
+	sdc := srv.AddBlock(udiskstest.Block{Name: "sdc", Size: 1 << 30})
+	sdd := srv.AddBlock(udiskstest.Block{Name: "sdd", Size: 1 << 30})
+	p := srv.AddMDRaid(udiskstest.MDRaid{UUID: "1", Level: "raid1", Members: []dbus.ObjectPath{sdb, sdc}, Size: 1 << 30, Running: true})
+	m := mdraid(t, c, p)
+
+	if err := c.RemoveMDRaidDevice(m, blockDevice(t, c, sdc), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := mdraid(t, c, p); got.Degraded != 1 || len(got.ActiveDevices) != 1 {
+		t.Errorf("unexpected RAID array after RemoveMDRaidDevice %+v", got)
+	}
+	if b := blockDevice(t, c, sdc); b.MDRaidMember != "" || b.IdUsage != "" {
+		t.Errorf("sdc not wiped after RemoveMDRaidDevice: %+v", b)
+	}
+
+	if err := c.AddMDRaidDevice(m, blockDevice(t, c, sdd)); err != nil {
+		t.Fatal(err)
+	}
+	got := mdraid(t, c, p)
+	if got.Degraded != 0 || len(got.ActiveDevices) != 2 {
+		t.Errorf("unexpected RAID array after AddMDRaidDevice %+v", got)
+	}
+	for _, d := range got.ActiveDevices {
+		if d.Block == string(sdd) && (d.Slot < 0 || len(d.State) != 1 || d.State[0] != "in_sync") {
+			t.Errorf("sdd did not replace the missing member: %+v", d)
+		}
+	}
+}
+
+func TestMDRaidBitmapAndSync(t *testing.T) {
+	srv, c := newTestClient(t)
+	sdb := srv.AddBlock(udiskstest.Block{Name: "sdb", Size: 1 << 30})
+	sdc := srv.AddBlock(udiskstest.Block{Name: "sdc", Size: 1 << 30})
+	p := srv.AddMDRaid(udiskstest.MDRaid{UUID: "1", Level: "raid1", Members: []dbus.ObjectPath{sdb, sdc}, Size: 1 << 30, Running: true})
+	m := mdraid(t, c, p)
+
+	if err := c.SetMDRaidBitmapLocation(m, "internal"); err != nil {
+		t.Fatal(err)
+	}
+	if m.BitmapLocation != "internal" || mdraid(t, c, p).BitmapLocation != "internal" {
+		t.Errorf("bitmap location %q after SetMDRaidBitmapLocation, want internal", m.BitmapLocation)
+	}
+	if err := c.SetMDRaidBitmapLocation(m, "/var/md.bitmap"); !errors.Is(err, udisks.ErrFailed) {
+		t.Errorf("SetMDRaidBitmapLocation to a file returned %v, want ErrFailed", err)
+	}
+
+	if err := c.RequestMDRaidSyncAction(m, udisks.MDRaidSyncCheck); err != nil {
+		t.Fatal(err)
+	}
+	if got := mdraid(t, c, p); got.SyncAction != "check" {
+		t.Errorf("sync action %q after RequestMDRaidSyncAction, want check", got.SyncAction)
+	}
+}
+
+func TestDeleteMDRaid(t *testing.T) {
+	srv, c := newTestClient(t)
+	sdb := srv.AddBlock(udiskstest.Block{Name: "sdb", Size: 1 << 30})
+	sdc := srv.AddBlock(udiskstest.Block{Name: "sdc", Size: 1 << 30})
+	p := srv.AddMDRaid(udiskstest.MDRaid{UUID: "1", Level: "raid1", Members: []dbus.ObjectPath{sdb, sdc}, Size: 1 << 30, Running: true})
+
+	if err := c.DeleteMDRaid(mdraid(t, c, p), true); err != nil {
+		t.Fatal(err)
+	}
+	if srv.HasObject(p) {
+		t.Error("RAID array still exported after DeleteMDRaid")
+	}
+	if b := blockDevice(t, c, sdb); b.MDRaidMember != "" {
+		t.Errorf("sdb still a member after DeleteMDRaid: %+v", b)
+	}
+	calls := srv.CallsTo("org.freedesktop.UDisks2.MDRaid", "Delete")
+	if opts := calls[0].Args[0].(map[string]dbus.Variant); opts["tear-down"].Value() != true || opts["auth.no_user_interaction"].Value() != true {
+		t.Errorf("unexpected Delete options %v", opts)
+	}
+}
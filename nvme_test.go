@@ -0,0 +1,64 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func addNamespace(srv *udiskstest.Server) dbus.ObjectPath {
+	formats := []udiskstest.LBAFormat{{DataSize: 512, RelativePerformance: 2}, {DataSize: 4096, RelativePerformance: 0}}
+	return srv.AddBlock(udiskstest.Block{Name: "nvme0n1", Size: 1 << 30, IdUsage: "filesystem", IdType: "ext4", NVMeNamespace: udiskstest.Props{
+		"NSID":             uint32(1),
+		"LBAFormats":       formats,
+		"FormattedLBASize": formats[0],
+		"NamespaceSize":    uint64(1 << 21),
+	}})
+}
+
+func TestNVMeNamespace(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := addNamespace(srv)
+
+	ns := blockDevice(t, c, p).NVMeNamespace
+	if ns == nil || ns.NSID != 1 || ns.NamespaceSize != 1<<21 || ns.FormattedLBASize.DataSize != 512 {
+		t.Fatalf("unexpected namespace %+v", ns)
+	}
+	if f, ok := ns.LBAFormat(4096); !ok || f.RelativePerformance != 0 {
+		t.Errorf("LBAFormat(4096) returned %+v, %v", f, ok)
+	}
+	if _, ok := ns.LBAFormat(520); ok {
+		t.Error("LBAFormat(520) found an unsupported format")
+	}
+}
+
+func TestFormatNamespace(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := addNamespace(srv)
+	b := blockDevice(t, c, p)
+
+	ns, err := c.FormatNamespace(b, udisks.NamespaceFormatOptions{LBADataSize: 4096, SecureErase: udisks.NVMeSecureEraseCrypto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns.FormattedLBASize.DataSize != 4096 || ns.NamespaceSize != 1<<18 {
+		t.Errorf("unexpected namespace after FormatNamespace: %+v", ns)
+	}
+	if b.NVMeNamespace.FormattedLBASize.DataSize != 512 {
+		t.Errorf("FormatNamespace changed b: %+v", b.NVMeNamespace)
+	}
+	opts := srv.CallsTo("org.freedesktop.UDisks2.NVMe.Namespace", "FormatNamespace")[0].Args[0].(map[string]dbus.Variant)
+	if opts["secure_erase"].Value() != "crypto_erase" || opts["lba_data_size"].Value() != uint16(4096) {
+		t.Errorf("unexpected FormatNamespace options %v", opts)
+	}
+
+	if _, err := c.FormatNamespace(b, udisks.NamespaceFormatOptions{LBADataSize: 520}); !errors.Is(err, udisks.ErrFailed) {
+		t.Errorf("FormatNamespace with an unsupported size returned %v, want ErrFailed", err)
+	}
+	if _, err := c.FormatNamespace(&udisks.BlockDevice{Device: "/org/freedesktop/UDisks2/block_devices/sda"}, udisks.NamespaceFormatOptions{}); !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("FormatNamespace without a namespace returned %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,214 @@
+package udisks
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Partition flags. The GPT flags are the attribute bits of the partition entry,
+// PartitionFlagBootable is the only flag used by dos tables.
+const (
+	PartitionFlagSystem             uint64 = 1 << 0
+	PartitionFlagLegacyBIOSBootable uint64 = 1 << 2
+	PartitionFlagReadOnly           uint64 = 1 << 60
+	PartitionFlagHidden             uint64 = 1 << 62
+	PartitionFlagNoAutomount        uint64 = 1 << 63
+	PartitionFlagBootable           uint64 = 0x80
+)
+
+type PartitionTable struct {
+	// Type is "gpt" or "dos"
+	Type       string
+	Partitions []*Partition
+}
+
+type Partition struct {
+	// Path is the object path of the block device of the partition
+	Path   string
+	Number uint32
+	// Type is the partition type GUID for gpt, or e.g. "0x83" for dos
+	Type   string
+	Flags  uint64
+	Offset uint64
+	Size   uint64
+	Name   string
+	UUID   string
+	// Table is the object path of the block device holding the partition table
+	Table       string
+	IsContainer bool
+	IsContained bool
+}
+
+// NewPartition describes a partition created by CreatePartition. Offset and
+// Size are in bytes, a Size of 0 uses the largest free space at Offset.
+type NewPartition struct {
+	Offset uint64
+	Size   uint64
+	Type   string
+	// Name is only supported by gpt
+	Name string
+	// PartitionType is "primary", "extended" or "logical" on dos tables
+	PartitionType string
+}
+
+func buildPartitionTable(props map[string]dbus.Variant) *PartitionTable {
+	pt := &PartitionTable{Partitions: []*Partition{}}
+	prop(props, "Type", &pt.Type)
+	return pt
+}
+
+func buildPartition(path dbus.ObjectPath, props map[string]dbus.Variant) *Partition {
+	p := &Partition{Path: string(path)}
+	prop(props, "Number", &p.Number)
+	prop(props, "Type", &p.Type)
+	prop(props, "Flags", &p.Flags)
+	prop(props, "Offset", &p.Offset)
+	prop(props, "Size", &p.Size)
+	prop(props, "Name", &p.Name)
+	prop(props, "UUID", &p.UUID)
+	prop(props, "IsContainer", &p.IsContainer)
+	prop(props, "IsContained", &p.IsContained)
+	p.Table = string(objectPathProperty(props, "Table"))
+	return p
+}
+
+// CreatePartition creates a partition in the partition table on the block device
+// and returns the block device of the new partition
+func (c *Client) CreatePartition(table *BlockDevice, p NewPartition) (*BlockDevice, error) {
+	return c.CreatePartitionContext(context.Background(), table, p)
+}
+
+func (c *Client) CreatePartitionContext(ctx context.Context, table *BlockDevice, p NewPartition) (*BlockDevice, error) {
+	var path dbus.ObjectPath
+	err := c.call(ctx, dbus.ObjectPath(table.Device), "org.freedesktop.UDisks2.PartitionTable.CreatePartition",
+		p.Offset, p.Size, p.Type, p.Name, p.options()).Store(&path)
+	if err != nil {
+		return nil, err
+	}
+	return c.blockDevice(ctx, path)
+}
+
+// CreatePartitionAndFormat creates a partition and formats it with fsType in one
+// step, see Format, and returns the block device of the new partition. As
+// there, opts.Erase lifts the default timeout.
+func (c *Client) CreatePartitionAndFormat(table *BlockDevice, p NewPartition, fsType string, opts FormatOptions) (*BlockDevice, error) {
+	return c.CreatePartitionAndFormatContext(context.Background(), table, p, fsType, opts)
+}
+
+func (c *Client) CreatePartitionAndFormatContext(ctx context.Context, table *BlockDevice, p NewPartition, fsType string, opts FormatOptions) (*BlockDevice, error) {
+	defer opts.zero()
+	if err := c.checkFormat(ctx, fsType); err != nil {
+		return nil, err
+	}
+	var path dbus.ObjectPath
+	err := opts.call(c)(ctx, dbus.ObjectPath(table.Device), "org.freedesktop.UDisks2.PartitionTable.CreatePartitionAndFormat",
+		p.Offset, p.Size, p.Type, p.Name, p.options(), fsType, opts.options()).Store(&path)
+	if err != nil {
+		return nil, err
+	}
+	return c.blockDevice(ctx, path)
+}
+
+func (p NewPartition) options() map[string]interface{} {
+	opt := defaultOptions()
+	if p.PartitionType != "" {
+		opt["partition-type"] = p.PartitionType
+	}
+	return opt
+}
+
+// DeletePartition deletes the partition on the block device. With tearDown the
+// file systems, encrypted containers and configuration on it are removed first.
+func (c *Client) DeletePartition(b *BlockDevice, tearDown bool) error {
+	return c.DeletePartitionContext(context.Background(), b, tearDown)
+}
+
+func (c *Client) DeletePartitionContext(ctx context.Context, b *BlockDevice, tearDown bool) error {
+	opt := defaultOptions()
+	if tearDown {
+		opt["tear-down"] = true
+	}
+	return c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Partition.Delete", opt).Err
+}
+
+// ResizePartition changes the size of the partition in bytes. The file system on
+// it is not resized. udisks aligns the partition, so b.Partition.Size is read
+// back and may be slightly larger than size.
+func (c *Client) ResizePartition(b *BlockDevice, size uint64) error {
+	return c.ResizePartitionContext(context.Background(), b, size)
+}
+
+func (c *Client) ResizePartitionContext(ctx context.Context, b *BlockDevice, size uint64) error {
+	if err := c.partitionCall(ctx, b, "Resize", size); err != nil || b.Partition == nil {
+		return err
+	}
+	var v dbus.Variant
+	err := c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.DBus.Properties.Get", "org.freedesktop.UDisks2.Partition", "Size").Store(&v)
+	if err != nil {
+		return err
+	}
+	size, ok := v.Value().(uint64)
+	if !ok {
+		return ErrInvalidPropertyFormat
+	}
+	b.Partition.Size = size
+	return nil
+}
+
+// SetPartitionType sets the type of the partition, a GUID such as
+// "0fc63daf-8483-4772-8e79-3d69d8477de4" on GPT or a hex code such as "0x83"
+// on MBR.
+func (c *Client) SetPartitionType(b *BlockDevice, partitionType string) error {
+	return c.SetPartitionTypeContext(context.Background(), b, partitionType)
+}
+
+func (c *Client) SetPartitionTypeContext(ctx context.Context, b *BlockDevice, partitionType string) error {
+	err := c.partitionCall(ctx, b, "SetType", partitionType)
+	if err == nil && b.Partition != nil {
+		b.Partition.Type = partitionType
+	}
+	return err
+}
+
+func (c *Client) SetPartitionName(b *BlockDevice, name string) error {
+	return c.SetPartitionNameContext(context.Background(), b, name)
+}
+
+func (c *Client) SetPartitionNameContext(ctx context.Context, b *BlockDevice, name string) error {
+	err := c.partitionCall(ctx, b, "SetName", name)
+	if err == nil && b.Partition != nil {
+		b.Partition.Name = name
+	}
+	return err
+}
+
+func (c *Client) SetPartitionFlags(b *BlockDevice, flags uint64) error {
+	return c.SetPartitionFlagsContext(context.Background(), b, flags)
+}
+
+func (c *Client) SetPartitionFlagsContext(ctx context.Context, b *BlockDevice, flags uint64) error {
+	err := c.partitionCall(ctx, b, "SetFlags", flags)
+	if err == nil && b.Partition != nil {
+		b.Partition.Flags = flags
+	}
+	return err
+}
+
+// SetPartitionUUID sets the unique partition GUID. Only GPT partitions have
+// one.
+func (c *Client) SetPartitionUUID(b *BlockDevice, uuid string) error {
+	return c.SetPartitionUUIDContext(context.Background(), b, uuid)
+}
+
+func (c *Client) SetPartitionUUIDContext(ctx context.Context, b *BlockDevice, uuid string) error {
+	err := c.partitionCall(ctx, b, "SetUUID", uuid)
+	if err == nil && b.Partition != nil {
+		b.Partition.UUID = uuid
+	}
+	return err
+}
+
+func (c *Client) partitionCall(ctx context.Context, b *BlockDevice, method string, arg interface{}) error {
+	return c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Partition."+method, arg, defaultOptions()).Err
+}
@@ -0,0 +1,112 @@
+package udisks_test
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestPartitions(t *testing.T) {
+	srv, c := newTestClient(t)
+	table := srv.AddBlock(udiskstest.Block{Name: "sda", Size: 1 << 30, PartitionTable: udiskstest.Props{"Type": "gpt", "Partitions": []dbus.ObjectPath{}}})
+
+	part, err := c.CreatePartition(blockDevice(t, c, table), udisks.NewPartition{Offset: 1 << 20, Size: 1 << 29, Type: "0fc63daf-8483-4772-8e79-3d69d8477de4", Name: "data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if part.Partition == nil || part.Partition.Number != 1 || part.Partition.Table != string(table) || part.Partition.Name != "data" {
+		t.Fatalf("unexpected partition %+v", part.Partition)
+	}
+	pt := blockDevice(t, c, table).PartitionTable
+	if pt == nil || pt.Type != "gpt" || len(pt.Partitions) != 1 || pt.Partitions[0].Path != part.Device {
+		t.Fatalf("unexpected partition table %+v", pt)
+	}
+
+	// udisks aligns the size, the partition gets the aligned one
+	if err := c.ResizePartition(part, 1<<28-4096); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPartitionName(part, "home"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPartitionFlags(part, udisks.PartitionFlagNoAutomount); err != nil {
+		t.Fatal(err)
+	}
+	const home = "933ac7e1-2eb4-4f13-b844-0e14e2aef915"
+	if err := c.SetPartitionType(part, home); err != nil {
+		t.Fatal(err)
+	}
+	const uuid = "6f1c0d3e-8f45-4a3b-9c2d-1e5b7a9f0c42"
+	if err := c.SetPartitionUUID(part, uuid); err != nil {
+		t.Fatal(err)
+	}
+	want := udisks.Partition{Size: 1 << 28, Name: "home", Flags: udisks.PartitionFlagNoAutomount, Type: home, UUID: uuid}
+	for _, got := range []*udisks.Partition{part.Partition, blockDevice(t, c, dbus.ObjectPath(part.Device)).Partition} {
+		if got.Size != want.Size || got.Name != want.Name || got.Flags != want.Flags || got.Type != want.Type || got.UUID != want.UUID {
+			t.Errorf("got partition %+v, want %+v", got, want)
+		}
+	}
+
+	if err := c.DeletePartition(part, true); err != nil {
+		t.Fatal(err)
+	}
+	calls := srv.CallsTo("org.freedesktop.UDisks2.Partition", "Delete")
+	if opts := calls[0].Args[0].(map[string]dbus.Variant); opts["tear-down"].Value() != true {
+		t.Errorf("tear-down option %v, want true", opts["tear-down"])
+	}
+	if srv.HasObject(dbus.ObjectPath(part.Device)) {
+		t.Error("partition still exported after DeletePartition")
+	}
+	if pt := blockDevice(t, c, table).PartitionTable; len(pt.Partitions) != 0 {
+		t.Errorf("partition table still lists %d partitions", len(pt.Partitions))
+	}
+}
+
+func TestCreatePartitionAndFormat(t *testing.T) {
+	srv, c := newTestClient(t)
+	table := srv.AddBlock(udiskstest.Block{Name: "sda", Size: 1 << 30, PartitionTable: udiskstest.Props{"Type": "gpt", "Partitions": []dbus.ObjectPath{}}})
+
+	part, err := c.CreatePartitionAndFormat(blockDevice(t, c, table), udisks.NewPartition{Offset: 1 << 20, Size: 1 << 29, Name: "data"}, "ext4", udisks.FormatOptions{Label: "backup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if part.Partition == nil || part.Partition.Table != string(table) || part.Partition.Name != "data" {
+		t.Errorf("unexpected partition %+v", part.Partition)
+	}
+	if part.IdType != "ext4" || part.IdLabel != "backup" || len(part.Filesystems) != 1 {
+		t.Errorf("partition not formatted: %+v", part)
+	}
+	call := srv.CallsTo("org.freedesktop.UDisks2.PartitionTable", "CreatePartitionAndFormat")[0]
+	if fsType := call.Args[5]; fsType != "ext4" {
+		t.Errorf("formatted with %v, want ext4", fsType)
+	}
+	if opts := call.Args[6].(map[string]dbus.Variant); opts["label"].Value() != "backup" {
+		t.Errorf("unexpected format options %v", opts)
+	}
+
+}
+
+func TestPartitionsWithoutPartitionsProperty(t *testing.T) {
+	srv, c := newTestClient(t)
+	// udisks before 2.7.2 has no Partitions property on the table
+	table := srv.AddBlock(udiskstest.Block{Name: "sda", PartitionTable: udiskstest.Props{"Type": "dos"}})
+	for _, p := range []struct {
+		name   string
+		number uint32
+	}{{"sda10", 10}, {"sda1", 1}, {"sda2", 2}} {
+		srv.AddBlock(udiskstest.Block{Name: p.name, Partition: udiskstest.Props{"Number": p.number, "Table": table}})
+	}
+	srv.AddBlock(udiskstest.Block{Name: "sdb1", Partition: udiskstest.Props{"Number": uint32(1), "Table": udiskstest.BlockPath("sdb")}})
+
+	pt := blockDevice(t, c, table).PartitionTable
+	if pt == nil || len(pt.Partitions) != 3 {
+		t.Fatalf("unexpected partition table %+v", pt)
+	}
+	for i, want := range []uint32{1, 2, 10} {
+		if pt.Partitions[i].Number != want {
+			t.Errorf("partition %d has number %d, want %d", i, pt.Partitions[i].Number, want)
+		}
+	}
+}
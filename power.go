@@ -0,0 +1,152 @@
+package udisks
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// PowerState is the power mode of an ATA drive as reported by CHECK POWER MODE
+type PowerState int
+
+const (
+	PowerStateUnknown PowerState = iota
+	// PowerStateStandby means the disk is spun down
+	PowerStateStandby
+	PowerStateIdle
+	PowerStateActive
+)
+
+func (s PowerState) String() string {
+	switch s {
+	case PowerStateStandby:
+		return "standby"
+	case PowerStateIdle:
+		return "idle"
+	case PowerStateActive:
+		return "active"
+	}
+	return "unknown"
+}
+
+// powerState decodes the count field returned by CHECK POWER MODE
+func powerState(v byte) PowerState {
+	switch v {
+	case 0x00, 0x01, 0x02, 0x40, 0x41:
+		return PowerStateStandby
+	case 0x80, 0x81, 0x82, 0x83:
+		return PowerStateIdle
+	case 0xff:
+		return PowerStateActive
+	}
+	return PowerStateUnknown
+}
+
+// DriveConfiguration holds the settings udisksd applies every time the drive
+// is connected. Nil fields are not configured.
+type DriveConfiguration struct {
+	// AtaPmStandby is the standby timeout as used by hdparm -S, 0 disables it
+	AtaPmStandby *int32
+	// AtaAPMLevel is the advanced power management level from 1 to 255
+	AtaAPMLevel *int32
+	// AtaAAMLevel is the automatic acoustic management level from 0 to 255
+	AtaAAMLevel             *int32
+	AtaWriteCacheEnabled    *bool
+	AtaReadLookaheadEnabled *bool
+}
+
+func buildDriveConfiguration(props map[string]dbus.Variant) DriveConfiguration {
+	var values map[string]dbus.Variant
+	prop(props, "Configuration", &values)
+	cfg := DriveConfiguration{}
+	cfg.AtaPmStandby = configValue[int32](values, "ata-pm-standby")
+	cfg.AtaAPMLevel = configValue[int32](values, "ata-apm-level")
+	cfg.AtaAAMLevel = configValue[int32](values, "ata-aam-level")
+	cfg.AtaWriteCacheEnabled = configValue[bool](values, "ata-write-cache-enabled")
+	cfg.AtaReadLookaheadEnabled = configValue[bool](values, "ata-read-lookahead-enabled")
+	return cfg
+}
+
+func configValue[T any](values map[string]dbus.Variant, name string) *T {
+	v, ok := values[name].Value().(T)
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func (cfg DriveConfiguration) values() map[string]interface{} {
+	values := map[string]interface{}{}
+	if cfg.AtaPmStandby != nil {
+		values["ata-pm-standby"] = *cfg.AtaPmStandby
+	}
+	if cfg.AtaAPMLevel != nil {
+		values["ata-apm-level"] = *cfg.AtaAPMLevel
+	}
+	if cfg.AtaAAMLevel != nil {
+		values["ata-aam-level"] = *cfg.AtaAAMLevel
+	}
+	if cfg.AtaWriteCacheEnabled != nil {
+		values["ata-write-cache-enabled"] = *cfg.AtaWriteCacheEnabled
+	}
+	if cfg.AtaReadLookaheadEnabled != nil {
+		values["ata-read-lookahead-enabled"] = *cfg.AtaReadLookaheadEnabled
+	}
+	return values
+}
+
+// PmGetState returns the power state of an ATA drive without waking it up
+func (c *Client) PmGetState(d *Drive) (PowerState, error) {
+	return c.PmGetStateContext(context.Background(), d)
+}
+
+func (c *Client) PmGetStateContext(ctx context.Context, d *Drive) (PowerState, error) {
+	if d.Ata == nil {
+		return PowerStateUnknown, ErrNotSupported
+	}
+	var state byte
+	err := c.call(ctx, dbus.ObjectPath(d.Path), "org.freedesktop.UDisks2.Drive.Ata.PmGetState", defaultOptions()).Store(&state)
+	if err != nil {
+		return PowerStateUnknown, err
+	}
+	return powerState(state), nil
+}
+
+// PmStandby spins down an ATA drive
+func (c *Client) PmStandby(d *Drive) error {
+	return c.PmStandbyContext(context.Background(), d)
+}
+
+func (c *Client) PmStandbyContext(ctx context.Context, d *Drive) error {
+	return c.pmCall(ctx, d, "PmStandby")
+}
+
+// PmWakeup spins up an ATA drive in standby
+func (c *Client) PmWakeup(d *Drive) error {
+	return c.PmWakeupContext(context.Background(), d)
+}
+
+func (c *Client) PmWakeupContext(ctx context.Context, d *Drive) error {
+	return c.pmCall(ctx, d, "PmWakeup")
+}
+
+func (c *Client) pmCall(ctx context.Context, d *Drive, method string) error {
+	if d.Ata == nil {
+		return ErrNotSupported
+	}
+	return c.call(ctx, dbus.ObjectPath(d.Path), "org.freedesktop.UDisks2.Drive.Ata."+method, defaultOptions()).Err
+}
+
+// SetDriveConfiguration replaces the persistent configuration of the drive.
+// udisksd applies it immediately and whenever the drive appears.
+func (c *Client) SetDriveConfiguration(d *Drive, cfg DriveConfiguration) error {
+	return c.SetDriveConfigurationContext(context.Background(), d, cfg)
+}
+
+func (c *Client) SetDriveConfigurationContext(ctx context.Context, d *Drive, cfg DriveConfiguration) error {
+	err := c.call(ctx, dbus.ObjectPath(d.Path), "org.freedesktop.UDisks2.Drive.SetConfiguration", cfg.values(), defaultOptions()).Err
+	if err == nil {
+		d.Configuration = cfg
+	}
+	return err
+}
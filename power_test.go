@@ -0,0 +1,73 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestPowerState(t *testing.T) {
+	_, c := newAtaTestClient(t)
+	d := drive(t, c, "ATA-Disk")
+
+	state, err := c.PmGetState(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != udisks.PowerStateActive {
+		t.Errorf("state %v, want active", state)
+	}
+	if err := c.PmStandby(d); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := c.PmGetState(d); state != udisks.PowerStateStandby {
+		t.Errorf("state %v after PmStandby, want standby", state)
+	}
+	if err := c.PmWakeup(d); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := c.PmGetState(d); state != udisks.PowerStateActive {
+		t.Errorf("state %v after PmWakeup, want active", state)
+	}
+}
+
+func TestPowerStateNotSupported(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{}})
+	d := drive(t, c, "NVMe-Disk")
+
+	if _, err := c.PmGetState(d); !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("PmGetState returned %v, want ErrNotSupported", err)
+	}
+	if err := c.PmStandby(d); !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("PmStandby returned %v, want ErrNotSupported", err)
+	}
+}
+
+func TestSetDriveConfiguration(t *testing.T) {
+	_, c := newAtaTestClient(t)
+	d := drive(t, c, "ATA-Disk")
+
+	standby, apm, cache := int32(120), int32(127), false
+	cfg := udisks.DriveConfiguration{AtaPmStandby: &standby, AtaAPMLevel: &apm, AtaWriteCacheEnabled: &cache}
+	if err := c.SetDriveConfiguration(d, cfg); err != nil {
+		t.Fatal(err)
+	}
+	got := drive(t, c, "ATA-Disk").Configuration
+	if got.AtaPmStandby == nil || *got.AtaPmStandby != 120 || got.AtaAPMLevel == nil || *got.AtaAPMLevel != 127 {
+		t.Errorf("unexpected configuration %+v", got)
+	}
+	if got.AtaWriteCacheEnabled == nil || *got.AtaWriteCacheEnabled || got.AtaAAMLevel != nil {
+		t.Errorf("unexpected configuration %+v", got)
+	}
+}
+
+// newAtaTestClient returns a client and a fake with an active ATA drive
+func newAtaTestClient(t *testing.T) (*udiskstest.Server, *udisks.Client) {
+	t.Helper()
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{"PmSupported": true, "PmEnabled": true, "ApmSupported": true}})
+	return srv, c
+}
@@ -0,0 +1,193 @@
+package udisks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var ErrSanitizeFailed = errors.New("sanitize failed")
+
+// SanitizeAction is the kind of NVMe sanitize operation
+type SanitizeAction string
+
+const (
+	SanitizeBlockErase  SanitizeAction = "block-erase"
+	SanitizeOverwrite   SanitizeAction = "overwrite"
+	SanitizeCryptoErase SanitizeAction = "crypto-erase"
+)
+
+// SanitizeOptions are the options of NVMeSanitize. The Overwrite options only
+// apply to SanitizeOverwrite.
+type SanitizeOptions struct {
+	// OverwritePassCount is the number of passes, 1 to 16. It is required for
+	// SanitizeOverwrite.
+	OverwritePassCount uint8
+	// OverwritePattern is the 32 bit pattern written to the media
+	OverwritePattern uint32
+	// OverwriteInvertPattern inverts the pattern between passes
+	OverwriteInvertPattern bool
+	// Progress is called with the fraction done, between 0 and 1, while the
+	// sanitize runs
+	Progress func(float64)
+	// Interactive allows udisks to ask for authentication through polkit
+	Interactive bool
+}
+
+// SanitizeError is returned by NVMeSanitize when the controller did not report
+// success. Status is the SanitizeStatus reported by udisks, e.g. "failure". It
+// matches ErrSanitizeFailed with errors.Is.
+type SanitizeError struct {
+	Status string
+}
+
+func (e *SanitizeError) Error() string {
+	return "sanitize failed: " + e.Status
+}
+
+func (e *SanitizeError) Is(target error) bool {
+	return target == ErrSanitizeFailed
+}
+
+func (o SanitizeOptions) options() map[string]interface{} {
+	opt := map[string]interface{}{
+		"auth.no_user_interaction": !o.Interactive,
+	}
+	if o.OverwritePassCount != 0 {
+		opt["overwrite_pass_count"] = o.OverwritePassCount
+	}
+	if o.OverwritePattern != 0 {
+		opt["overwrite_pattern"] = o.OverwritePattern
+	}
+	if o.OverwriteInvertPattern {
+		opt["overwrite_invert_pattern"] = true
+	}
+	return opt
+}
+
+// NVMeSanitize erases all user data of the NVMe drive and blocks until the
+// sanitize finished, which can take hours. It returns the final state of the
+// controller, d is left unchanged. A *SanitizeError is returned unless the
+// status is "success". Only ctx bounds the wait, the default timeout of the
+// client is not applied.
+func (c *Client) NVMeSanitize(d *Drive, action SanitizeAction, opts SanitizeOptions) (*NVMeController, error) {
+	return c.NVMeSanitizeContext(context.Background(), d, action, opts)
+}
+
+func (c *Client) NVMeSanitizeContext(ctx context.Context, d *Drive, action SanitizeAction, opts SanitizeOptions) (*NVMeController, error) {
+	if d.NVMeController == nil {
+		return nil, ErrNotSupported
+	}
+	if action == SanitizeOverwrite && (opts.OverwritePassCount < 1 || opts.OverwritePassCount > 16) {
+		return nil, fmt.Errorf("%w: overwrite pass count %d is not between 1 and 16", ErrInvalidOption, opts.OverwritePassCount)
+	}
+	path := dbus.ObjectPath(d.Path)
+	sigs, unsubscribe, err := c.subscribe(ctx, [][]dbus.MatchOption{
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchInterface("org.freedesktop.DBus.ObjectManager"),
+			dbus.WithMatchMember("InterfacesAdded"),
+			dbus.WithMatchArgPath(0, "/org/freedesktop/UDisks2/jobs/"),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchInterface("org.freedesktop.DBus.ObjectManager"),
+			dbus.WithMatchMember("InterfacesRemoved"),
+			dbus.WithMatchArgPath(0, string(path)),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchObjectPath(path),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchArg(0, "org.freedesktop.UDisks2.NVMe.Controller"),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchPathNamespace("/org/freedesktop/UDisks2/jobs"),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchArg(0, "org.freedesktop.UDisks2.Job"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer unsubscribe()
+
+	// udisksd only replies once the sanitize is done, progress is reported by
+	// its job and the SanitizePercentRemaining property in the meantime
+	done := make(chan error, 1)
+	go func() {
+		done <- c.callNoDefaultTimeout(ctx, path, "org.freedesktop.UDisks2.NVMe.Controller.SanitizeStart", string(action), opts.options()).Err
+	}()
+	var job dbus.ObjectPath
+	for running := true; running; {
+		select {
+		case err := <-done:
+			if err != nil {
+				return nil, err
+			}
+			running = false
+		case sig, ok := <-sigs:
+			if !ok {
+				return nil, errSignalsClosed
+			}
+			if len(sig.Body) < 2 {
+				continue
+			}
+			switch sig.Name {
+			case "org.freedesktop.DBus.ObjectManager.InterfacesAdded":
+				p, _ := sig.Body[0].(dbus.ObjectPath)
+				ifaces, _ := sig.Body[1].(map[string]map[string]dbus.Variant)
+				if props, ok := ifaces["org.freedesktop.UDisks2.Job"]; ok && buildJob(c, p, props).Affects(d.Path) {
+					job = p
+				}
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				if opts.Progress == nil {
+					continue
+				}
+				iface, _ := sig.Body[0].(string)
+				changed, _ := sig.Body[1].(map[string]dbus.Variant)
+				if job != "" && sig.Path == job && iface == "org.freedesktop.UDisks2.Job" {
+					progress := -1.0
+					prop(changed, "Progress", &progress)
+					if progress >= 0 {
+						opts.Progress(progress)
+					}
+				}
+				if sig.Path == path && iface == "org.freedesktop.UDisks2.NVMe.Controller" {
+					remaining := int32(-1)
+					prop(changed, "SanitizePercentRemaining", &remaining)
+					if remaining >= 0 {
+						opts.Progress(1 - float64(remaining)/100)
+					}
+				}
+			}
+		}
+	}
+
+	var props map[string]dbus.Variant
+	err = c.call(ctx, path, "org.freedesktop.DBus.Properties.GetAll", "org.freedesktop.UDisks2.NVMe.Controller").Store(&props)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ctrl := buildNVMeController(props)
+		switch ctrl.SanitizeStatus {
+		case "success":
+			return ctrl, nil
+		case "inprogress":
+		default:
+			return ctrl, &SanitizeError{Status: ctrl.SanitizeStatus}
+		}
+		if remaining := ctrl.SanitizePercentRemaining; opts.Progress != nil && remaining >= 0 {
+			opts.Progress(1 - float64(remaining)/100)
+		}
+		if err := waitPropertiesChanged(ctx, sigs, path, "org.freedesktop.UDisks2.NVMe.Controller", props, ErrDriveNotFound); err != nil {
+			return nil, err
+		}
+	}
+}
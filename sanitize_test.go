@@ -0,0 +1,151 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestNVMeSanitize(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{"SanitizeStatus": "never_sanitized"}})
+	d := drive(t, c, "NVMe-Disk")
+	// the reply may overtake the progress signals, so it is held back until
+	// the client reported progress
+	reported := make(chan struct{})
+	srv.Handle("org.freedesktop.UDisks2.NVMe.Controller", "SanitizeStart", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		const iface = "org.freedesktop.UDisks2.NVMe.Controller"
+		job := srv.AddJob(udiskstest.Props{"Operation": "nvme-sanitize", "Objects": []dbus.ObjectPath{drv}, "Cancelable": false})
+		srv.SetProperties(drv, iface, udiskstest.Props{"SanitizeStatus": "inprogress", "SanitizePercentRemaining": int32(50)})
+		select {
+		case <-reported:
+		case <-time.After(5 * time.Second):
+		}
+		srv.SetProperties(drv, iface, udiskstest.Props{"SanitizeStatus": "success", "SanitizePercentRemaining": int32(-1)})
+		srv.CompleteJob(job, true, "")
+		return nil, nil
+	})
+
+	var progress []float64
+	ctrl, err := c.NVMeSanitize(d, udisks.SanitizeOverwrite, udisks.SanitizeOptions{
+		OverwritePassCount: 2,
+		OverwritePattern:   0xdeadbeef,
+		Progress: func(p float64) {
+			if p == 0.5 && !contains(progress, 0.5) {
+				close(reported)
+			}
+			progress = append(progress, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctrl.SanitizeStatus != "success" {
+		t.Errorf("status %q after NVMeSanitize, want success", ctrl.SanitizeStatus)
+	}
+	if d.NVMeController.SanitizeStatus != "never_sanitized" {
+		t.Errorf("NVMeSanitize changed d: %+v", d.NVMeController)
+	}
+	if !contains(progress, 0.5) {
+		t.Errorf("progress %v does not report 0.5", progress)
+	}
+	opts := srv.CallsTo("org.freedesktop.UDisks2.NVMe.Controller", "SanitizeStart")[0].Args[1].(map[string]dbus.Variant)
+	if opts["overwrite_pass_count"].Value() != byte(2) || opts["overwrite_pattern"].Value() != uint32(0xdeadbeef) {
+		t.Errorf("unexpected sanitize options %v", opts)
+	}
+}
+
+func TestNVMeSanitizeProgressOfOtherDrive(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{}})
+	other := srv.AddDrive(udiskstest.Drive{Id: "Other-NVMe-Disk", NVMeController: udiskstest.Props{}})
+	srv.Handle("org.freedesktop.UDisks2.NVMe.Controller", "SanitizeStart", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		srv.SetProperty(other, "org.freedesktop.UDisks2.NVMe.Controller", "SanitizePercentRemaining", int32(90))
+		srv.SetProperties(drv, "org.freedesktop.UDisks2.NVMe.Controller", udiskstest.Props{"SanitizeStatus": "inprogress", "SanitizePercentRemaining": int32(25)})
+		srv.SetProperties(drv, "org.freedesktop.UDisks2.NVMe.Controller", udiskstest.Props{"SanitizeStatus": "success", "SanitizePercentRemaining": int32(-1)})
+		return nil, nil
+	})
+
+	var progress []float64
+	_, err := c.NVMeSanitize(drive(t, c, "NVMe-Disk"), udisks.SanitizeBlockErase, udisks.SanitizeOptions{
+		Progress: func(p float64) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range progress {
+		if p != 0.75 {
+			t.Errorf("progress %v includes the progress of another drive", progress)
+		}
+	}
+}
+
+func TestNVMeSanitizeFailed(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{}})
+	srv.Handle("org.freedesktop.UDisks2.NVMe.Controller", "SanitizeStart", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		srv.SetProperty(drv, "org.freedesktop.UDisks2.NVMe.Controller", "SanitizeStatus", "failure")
+		return nil, nil
+	})
+
+	ctrl, err := c.NVMeSanitize(drive(t, c, "NVMe-Disk"), udisks.SanitizeCryptoErase, udisks.SanitizeOptions{})
+	var e *udisks.SanitizeError
+	if !errors.As(err, &e) || e.Status != "failure" || !errors.Is(err, udisks.ErrSanitizeFailed) {
+		t.Errorf("NVMeSanitize returned %v, want a *SanitizeError", err)
+	}
+	if ctrl == nil || ctrl.SanitizeStatus != "failure" {
+		t.Errorf("NVMeSanitize returned the controller %+v, want its final state", ctrl)
+	}
+}
+
+func TestNVMeSanitizeConnectionClosed(t *testing.T) {
+	srv, _ := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{}})
+	conn, err := srv.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := udisks.NewClientWithConn(conn)
+	d := drive(t, c, "NVMe-Disk")
+	srv.Handle("org.freedesktop.UDisks2.NVMe.Controller", "SanitizeStart", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		conn.Close()
+		return nil, nil
+	})
+
+	if _, err := c.NVMeSanitize(d, udisks.SanitizeBlockErase, udisks.SanitizeOptions{}); err == nil {
+		t.Error("NVMeSanitize succeeded although the connection was closed")
+	}
+}
+
+func TestNVMeSanitizeInvalidOptions(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{}})
+	srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{}})
+	d := drive(t, c, "NVMe-Disk")
+
+	for _, n := range []uint8{0, 17} {
+		_, err := c.NVMeSanitize(d, udisks.SanitizeOverwrite, udisks.SanitizeOptions{OverwritePassCount: n})
+		if !errors.Is(err, udisks.ErrInvalidOption) {
+			t.Errorf("pass count %d: got %v, want ErrInvalidOption", n, err)
+		}
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.NVMe.Controller", "SanitizeStart"); len(calls) != 0 {
+		t.Error("SanitizeStart called with an invalid pass count")
+	}
+	if _, err := c.NVMeSanitize(drive(t, c, "ATA-Disk"), udisks.SanitizeBlockErase, udisks.SanitizeOptions{}); !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("NVMeSanitize of an ATA drive returned %v, want ErrNotSupported", err)
+	}
+}
+
+func contains(progress []float64, p float64) bool {
+	for _, v := range progress {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package udisks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var ErrSelfTestFailed = errors.New("self-test failed")
+var ErrSelfTestAborted = errors.New("self-test aborted")
+var ErrNoSelfTest = errors.New("no self-test running")
+
+// SelfTestType is the kind of SMART self-test to run
+type SelfTestType string
+
+const (
+	SelfTestShort    SelfTestType = "short"
+	SelfTestExtended SelfTestType = "extended"
+	// SelfTestConveyance is only supported by ATA drives
+	SelfTestConveyance SelfTestType = "conveyance"
+)
+
+// SelfTestError is returned by WaitSelfTest when the self-test found a problem.
+// Status is the SmartSelftestStatus reported by udisks, e.g. "error_read" or
+// "known_seg_fail". It matches ErrSelfTestFailed with errors.Is.
+type SelfTestError struct {
+	Status string
+}
+
+func (e *SelfTestError) Error() string {
+	return "self-test failed: " + e.Status
+}
+
+func (e *SelfTestError) Is(target error) bool {
+	return target == ErrSelfTestFailed
+}
+
+// StartSelfTest starts a SMART self-test on an ATA or NVMe drive. Use
+// WaitSelfTest to wait for the result.
+func (c *Client) StartSelfTest(d *Drive, t SelfTestType) error {
+	return c.StartSelfTestContext(context.Background(), d, t)
+}
+
+func (c *Client) StartSelfTestContext(ctx context.Context, d *Drive, t SelfTestType) error {
+	iface, err := smartInterface(d)
+	if err != nil {
+		return err
+	}
+	if t == SelfTestConveyance && d.Ata == nil {
+		return fmt.Errorf("%w: conveyance self-test requires an ATA drive", ErrNotSupported)
+	}
+	return c.call(ctx, dbus.ObjectPath(d.Path), iface+".SmartSelftestStart", string(t), defaultOptions()).Err
+}
+
+// AbortSelfTest aborts the running SMART self-test of the drive
+func (c *Client) AbortSelfTest(d *Drive) error {
+	return c.AbortSelfTestContext(context.Background(), d)
+}
+
+func (c *Client) AbortSelfTestContext(ctx context.Context, d *Drive) error {
+	iface, err := smartInterface(d)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, dbus.ObjectPath(d.Path), iface+".SmartSelftestAbort", defaultOptions()).Err
+}
+
+// WaitSelfTest blocks until the running self-test of the drive is done and
+// returns its result. udisksd reports a test as in progress before
+// StartSelfTest returns; if no test is in progress any more, the result of the
+// last one is returned right away. d is only used to find the drive and is
+// left unchanged.
+//
+// ErrNoSelfTest is returned if the drive reports no self-test. An aborted or
+// interrupted test returns ErrSelfTestAborted and a failed one a
+// *SelfTestError.
+func (c *Client) WaitSelfTest(ctx context.Context, d *Drive) error {
+	iface, err := smartInterface(d)
+	if err != nil {
+		return err
+	}
+	path := dbus.ObjectPath(d.Path)
+	sigs, unsubscribe, err := c.subscribe(ctx, [][]dbus.MatchOption{
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchObjectPath(path),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchInterface("org.freedesktop.DBus.ObjectManager"),
+			dbus.WithMatchMember("InterfacesRemoved"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer unsubscribe()
+
+	var props map[string]dbus.Variant
+	err = c.call(ctx, path, "org.freedesktop.DBus.Properties.GetAll", iface).Store(&props)
+	if err != nil {
+		return err
+	}
+	for {
+		var status string
+		prop(props, "SmartSelftestStatus", &status)
+		if status != "inprogress" {
+			return selfTestResult(status)
+		}
+		if err := waitPropertiesChanged(ctx, sigs, path, iface, props, ErrDriveNotFound); err != nil {
+			return err
+		}
+	}
+}
+
+// selfTestResult maps the SmartSelftestStatus of a finished ATA or NVMe
+// self-test to the error returned by WaitSelfTest
+func selfTestResult(status string) error {
+	switch status {
+	case "success":
+		return nil
+	case "aborted", "interrupted", "ctrl_reset", "ns_removed", "aborted_format", "aborted_sanitize", "aborted_unknown":
+		return fmt.Errorf("%w: %s", ErrSelfTestAborted, status)
+	case "fatal", "error_unknown", "error_electrical", "error_servo", "error_read", "error_handling",
+		"fatal_error", "known_seg_fail", "unknown_seg_fail":
+		return &SelfTestError{Status: status}
+	}
+	// empty or unknown, e.g. for a drive that never ran a self-test
+	return ErrNoSelfTest
+}
@@ -0,0 +1,120 @@
+package udisks_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+// waitSelfTest runs WaitSelfTest in the background and gives it time to
+// subscribe to property changes before returning
+func waitSelfTest(t *testing.T, c *udisks.Client, d *udisks.Drive) <-chan error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	result := make(chan error, 1)
+	go func() { result <- c.WaitSelfTest(ctx, d) }()
+	time.Sleep(100 * time.Millisecond)
+	return result
+}
+
+// newNVMeTestClient returns a client and a fake with an idle NVMe drive
+func newNVMeTestClient(t *testing.T) (*udiskstest.Server, *udisks.Client) {
+	t.Helper()
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{"SmartSelftestStatus": "success"}})
+	return srv, c
+}
+
+func TestSelfTest(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{"SmartSupported": true, "SmartSelftestStatus": "error_read"}})
+	d := drive(t, c, "ATA-Disk")
+
+	if err := c.StartSelfTest(d, udisks.SelfTestShort); err != nil {
+		t.Fatal(err)
+	}
+	result := waitSelfTest(t, c, d)
+	select {
+	case err := <-result:
+		t.Fatalf("WaitSelfTest returned %v while the test was running", err)
+	default:
+	}
+	srv.SetProperties(drv, "org.freedesktop.UDisks2.Drive.Ata", udiskstest.Props{"SmartSelftestPercentRemaining": int32(0), "SmartSelftestStatus": "success"})
+	if err := <-result; err != nil {
+		t.Fatalf("WaitSelfTest returned %v", err)
+	}
+	if d.Ata.SmartSelftestStatus != "error_read" {
+		t.Errorf("the self-test changed d: %+v", d.Ata)
+	}
+}
+
+func TestWaitSelfTestAlreadyFinished(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{"SmartSupported": true, "SmartSelftestStatus": "inprogress"}})
+	// d still reports the test in progress, but it finished in the meantime
+	d := drive(t, c, "ATA-Disk")
+	srv.SetProperty(drv, "org.freedesktop.UDisks2.Drive.Ata", "SmartSelftestStatus", "error_read")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var e *udisks.SelfTestError
+	if err := c.WaitSelfTest(ctx, d); !errors.As(err, &e) || e.Status != "error_read" {
+		t.Errorf("WaitSelfTest returned %v, want the result of the finished test", err)
+	}
+}
+
+func TestWaitSelfTestNotStarted(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{"SmartSelftestStatus": ""}})
+
+	if err := c.WaitSelfTest(context.Background(), drive(t, c, "NVMe-Disk")); !errors.Is(err, udisks.ErrNoSelfTest) {
+		t.Errorf("WaitSelfTest returned %v, want ErrNoSelfTest", err)
+	}
+	srv.SetProperty(drv, "org.freedesktop.UDisks2.NVMe.Controller", "SmartSelftestStatus", "something_new")
+	if err := c.WaitSelfTest(context.Background(), drive(t, c, "NVMe-Disk")); !errors.Is(err, udisks.ErrNoSelfTest) {
+		t.Errorf("WaitSelfTest with an unknown status returned %v, want ErrNoSelfTest", err)
+	}
+}
+
+func TestSelfTestAborted(t *testing.T) {
+	_, c := newNVMeTestClient(t)
+	d := drive(t, c, "NVMe-Disk")
+	if err := c.StartSelfTest(d, udisks.SelfTestExtended); err != nil {
+		t.Fatal(err)
+	}
+	result := waitSelfTest(t, c, d)
+	if err := c.AbortSelfTest(d); err != nil {
+		t.Fatal(err)
+	}
+	err := <-result
+	if !errors.Is(err, udisks.ErrSelfTestAborted) || errors.Is(err, udisks.ErrSelfTestFailed) {
+		t.Errorf("WaitSelfTest returned %v, want ErrSelfTestAborted", err)
+	}
+}
+
+func TestSelfTestFailed(t *testing.T) {
+	srv, c := newNVMeTestClient(t)
+	d := drive(t, c, "NVMe-Disk")
+	if err := c.StartSelfTest(d, udisks.SelfTestShort); err != nil {
+		t.Fatal(err)
+	}
+	result := waitSelfTest(t, c, d)
+	srv.SetProperty(udiskstest.DrivePath("NVMe-Disk"), "org.freedesktop.UDisks2.NVMe.Controller", "SmartSelftestStatus", "known_seg_fail")
+	err := <-result
+	var e *udisks.SelfTestError
+	if !errors.As(err, &e) || e.Status != "known_seg_fail" || !errors.Is(err, udisks.ErrSelfTestFailed) {
+		t.Errorf("WaitSelfTest returned %v, want a *SelfTestError", err)
+	}
+}
+
+func TestConveyanceSelfTestRequiresAta(t *testing.T) {
+	_, c := newNVMeTestClient(t)
+	if err := c.StartSelfTest(drive(t, c, "NVMe-Disk"), udisks.SelfTestConveyance); !errors.Is(err, udisks.ErrNotSupported) {
+		t.Errorf("StartSelfTest returned %v, want ErrNotSupported", err)
+	}
+}
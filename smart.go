@@ -0,0 +1,65 @@
+package udisks
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// RefreshOptions are the options of RefreshSmart
+type RefreshOptions struct {
+	// NoWakeup fails with ErrWouldWakeup instead of waking up a disk in standby.
+	// It only applies to ATA drives.
+	NoWakeup bool
+	// AtaBlob is a libatasmart blob used instead of reading from the disk, for
+	// testing
+	AtaBlob []byte
+}
+
+// smartInterface returns the interface implementing the SMART methods of the
+// drive
+func smartInterface(d *Drive) (string, error) {
+	switch {
+	case d.Ata != nil:
+		return "org.freedesktop.UDisks2.Drive.Ata", nil
+	case d.NVMeController != nil:
+		return "org.freedesktop.UDisks2.NVMe.Controller", nil
+	}
+	return "", ErrSmartNotSupported
+}
+
+// RefreshSmart makes udisks read the SMART data of the drive again and returns
+// the refreshed data: *Ata for ATA drives, *NVMeController for NVMe drives, the
+// other one is nil. d is left unchanged, as it may be shared with a Snapshot.
+func (c *Client) RefreshSmart(d *Drive, opts RefreshOptions) (*Ata, *NVMeController, error) {
+	return c.RefreshSmartContext(context.Background(), d, opts)
+}
+
+func (c *Client) RefreshSmartContext(ctx context.Context, d *Drive, opts RefreshOptions) (*Ata, *NVMeController, error) {
+	iface, err := smartInterface(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	opt := defaultOptions()
+	if d.Ata != nil {
+		if opts.NoWakeup {
+			opt["nowakeup"] = true
+		}
+		if opts.AtaBlob != nil {
+			opt["atasmart_blob"] = opts.AtaBlob
+		}
+	}
+	path := dbus.ObjectPath(d.Path)
+	if err := c.call(ctx, path, iface+".SmartUpdate", opt).Err; err != nil {
+		return nil, nil, err
+	}
+	var props map[string]dbus.Variant
+	err = c.call(ctx, path, "org.freedesktop.DBus.Properties.GetAll", iface).Store(&props)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.Ata != nil {
+		return buildAta(props), nil, nil
+	}
+	return nil, buildNVMeController(props), nil
+}
@@ -0,0 +1,65 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestRefreshSmart(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{"SmartSupported": true, "SmartUpdated": uint64(0)}})
+	srv.AddDrive(udiskstest.Drive{Id: "NVMe-Disk", NVMeController: udiskstest.Props{"SmartUpdated": uint64(0)}})
+	s, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := s.DriveById("ATA-Disk")
+	ata, nvme, err := c.RefreshSmart(d, udisks.RefreshOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ata == nil || nvme != nil || ata.SmartUpdated == 0 {
+		t.Errorf("unexpected refreshed data %+v, %+v", ata, nvme)
+	}
+	if d.Ata.SmartUpdated != 0 {
+		t.Error("RefreshSmart changed the drive of the snapshot")
+	}
+
+	ata, nvme, err = c.RefreshSmart(s.DriveById("NVMe-Disk"), udisks.RefreshOptions{NoWakeup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ata != nil || nvme == nil || nvme.SmartUpdated == 0 {
+		t.Errorf("unexpected refreshed data %+v, %+v", ata, nvme)
+	}
+	// nowakeup is only sent to ATA drives
+	calls := srv.CallsTo("org.freedesktop.UDisks2.NVMe.Controller", "SmartUpdate")
+	if len(calls) != 1 {
+		t.Fatalf("got %d SmartUpdate calls, want 1", len(calls))
+	}
+	if opts := calls[0].Args[0].(map[string]dbus.Variant); opts["nowakeup"].Value() != nil {
+		t.Errorf("unexpected SmartUpdate options %v", opts)
+	}
+}
+
+func TestRefreshSmartNoWakeup(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "ATA-Disk", Ata: udiskstest.Props{"SmartSupported": true}})
+	srv.SetStandby(drv, true)
+	d := drive(t, c, "ATA-Disk")
+
+	if _, _, err := c.RefreshSmart(d, udisks.RefreshOptions{NoWakeup: true}); !errors.Is(err, udisks.ErrWouldWakeup) {
+		t.Errorf("RefreshSmart returned %v, want ErrWouldWakeup", err)
+	}
+	if _, _, err := c.RefreshSmart(d, udisks.RefreshOptions{}); err != nil {
+		t.Errorf("RefreshSmart without nowakeup returned %v", err)
+	}
+	if _, _, err := c.RefreshSmart(&udisks.Drive{Path: string(drv)}, udisks.RefreshOptions{}); !errors.Is(err, udisks.ErrSmartNotSupported) {
+		t.Errorf("RefreshSmart of a drive without SMART returned %v, want ErrSmartNotSupported", err)
+	}
+}
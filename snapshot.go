@@ -0,0 +1,335 @@
+package udisks
+
+import (
+	"context"
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Snapshot is a view of all drives and block devices known to UDisks, built
+// from a single GetManagedObjects call. Cross references such as
+// BlockDevice.Drive point at the entries in Drives.
+type Snapshot struct {
+	Manager        *Manager
+	Drives         []*Drive
+	BlockDevices   BlockDevices
+	Jobs           []*Job
+	MDRaids        []*MDRaid
+	VolumeGroups   []*VolumeGroup
+	LogicalVolumes []*LogicalVolume
+}
+
+// DriveById returns the drive with the given id or nil if it is not present
+func (s *Snapshot) DriveById(id string) *Drive {
+	for _, d := range s.Drives {
+		if d.Id == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) driveByPath(path string) *Drive {
+	for _, d := range s.Drives {
+		if d.Path == path {
+			return d
+		}
+	}
+	return nil
+}
+
+// BlockDevicesOnDrive returns the block devices located on the drive with the
+// given id, including the cleartext devices of encrypted containers on it
+func (s *Snapshot) BlockDevicesOnDrive(id string) BlockDevices {
+	blockDevices := make(BlockDevices, 0)
+	for _, b := range s.BlockDevices {
+		if b.CryptoBackingDevice != nil {
+			if b.CryptoBackingDevice.CleartextDevicePath != "" {
+				cryptoDrive := s.BlockDevices.ByDevice(b.CryptoBackingDevice.Path)
+				if cryptoDrive != nil && cryptoDrive.Drive != nil && cryptoDrive.Drive.Id == id {
+					blockDevices = append(blockDevices, b)
+				}
+			}
+		} else {
+			if b.Drive != nil && b.Drive.Id == id {
+				blockDevices = append(blockDevices, b)
+			}
+		}
+	}
+	return blockDevices
+}
+
+// VolumeGroupByName returns the volume group with the given name or nil if it
+// is not present
+func (s *Snapshot) VolumeGroupByName(name string) *VolumeGroup {
+	for _, vg := range s.VolumeGroups {
+		if vg.Name == name {
+			return vg
+		}
+	}
+	return nil
+}
+
+// LogicalVolumesInGroup returns the logical volumes of the named volume group
+func (s *Snapshot) LogicalVolumesInGroup(name string) []*LogicalVolume {
+	lvs := []*LogicalVolume{}
+	for _, lv := range s.LogicalVolumes {
+		if lv.VolumeGroup != nil && lv.VolumeGroup.Name == name {
+			lvs = append(lvs, lv)
+		}
+	}
+	return lvs
+}
+
+// PhysicalVolumesInGroup returns the block devices used by the named volume
+// group
+func (s *Snapshot) PhysicalVolumesInGroup(name string) BlockDevices {
+	blockDevices := make(BlockDevices, 0)
+	for _, b := range s.BlockDevices {
+		if b.PhysicalVolume != nil && b.PhysicalVolume.VolumeGroup != nil && b.PhysicalVolume.VolumeGroup.Name == name {
+			blockDevices = append(blockDevices, b)
+		}
+	}
+	return blockDevices
+}
+
+// Snapshot fetches every UDisks object in one round trip and returns the
+// drives, block devices, jobs, RAID arrays and LVM2 volumes built from it
+func (c *Client) Snapshot() (*Snapshot, error) {
+	return c.SnapshotContext(context.Background())
+}
+
+func (c *Client) SnapshotContext(ctx context.Context) (*Snapshot, error) {
+	objs, err := c.managedObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return buildSnapshot(c, objs), nil
+}
+
+// blockDevice returns the block device at path from a fresh snapshot, used to
+// return objects created by a method call
+func (c *Client) blockDevice(ctx context.Context, path dbus.ObjectPath) (*BlockDevice, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dev := s.BlockDevices.ByDevice(string(path)); dev != nil {
+		return dev, nil
+	}
+	return &BlockDevice{Device: string(path)}, nil
+}
+
+func buildSnapshot(c *Client, objs managedObjects) *Snapshot {
+	paths := make([]string, 0, len(objs))
+	for p := range objs {
+		paths = append(paths, string(p))
+	}
+	sort.Strings(paths)
+
+	s := &Snapshot{
+		Manager:        buildManager(objs["/org/freedesktop/UDisks2/Manager"]["org.freedesktop.UDisks2.Manager"]),
+		Drives:         []*Drive{},
+		BlockDevices:   BlockDevices{},
+		Jobs:           []*Job{},
+		MDRaids:        []*MDRaid{},
+		VolumeGroups:   []*VolumeGroup{},
+		LogicalVolumes: []*LogicalVolume{},
+	}
+	drives := map[dbus.ObjectPath]*Drive{}
+	blocks := map[dbus.ObjectPath]*BlockDevice{}
+	vgs := map[dbus.ObjectPath]*VolumeGroup{}
+	lvs := map[dbus.ObjectPath]*LogicalVolume{}
+	for _, p := range paths {
+		path := dbus.ObjectPath(p)
+		ifaces := objs[path]
+		if _, ok := ifaces["org.freedesktop.UDisks2.Drive"]; ok {
+			drv := buildDrive(path, ifaces)
+			drives[path] = drv
+			s.Drives = append(s.Drives, drv)
+		}
+		if job, ok := ifaces["org.freedesktop.UDisks2.Job"]; ok {
+			s.Jobs = append(s.Jobs, buildJob(c, path, job))
+		}
+		if raid, ok := ifaces["org.freedesktop.UDisks2.MDRaid"]; ok {
+			s.MDRaids = append(s.MDRaids, buildMDRaid(path, raid))
+		}
+		if group, ok := ifaces["org.freedesktop.UDisks2.VolumeGroup"]; ok {
+			vg := buildVolumeGroup(path, group)
+			vgs[path] = vg
+			s.VolumeGroups = append(s.VolumeGroups, vg)
+		}
+	}
+	for _, p := range paths {
+		path := dbus.ObjectPath(p)
+		volume, ok := objs[path]["org.freedesktop.UDisks2.LogicalVolume"]
+		if !ok {
+			continue
+		}
+		lv := buildLogicalVolume(path, volume)
+		lv.VolumeGroup = vgs[objectPathProperty(volume, "VolumeGroup")]
+		lvs[path] = lv
+		s.LogicalVolumes = append(s.LogicalVolumes, lv)
+	}
+	for _, p := range paths {
+		path := dbus.ObjectPath(p)
+		ifaces := objs[path]
+		block, ok := ifaces["org.freedesktop.UDisks2.Block"]
+		if !ok {
+			continue
+		}
+		dev := buildBlockDevice(path, ifaces)
+		if drv := objectPathProperty(block, "Drive"); drv != "" {
+			dev.Drive = drives[drv]
+		}
+		if cbd := objectPathProperty(block, "CryptoBackingDevice"); cbd != "" {
+			if enc, ok := objs[cbd]["org.freedesktop.UDisks2.Encrypted"]; ok {
+				dev.CryptoBackingDevice = buildCryptoBackingDevice(cbd, enc)
+			}
+		}
+		if lvm, ok := ifaces["org.freedesktop.UDisks2.Block.LVM2"]; ok {
+			dev.LogicalVolume = lvs[objectPathProperty(lvm, "LogicalVolume")]
+		}
+		if pv, ok := ifaces["org.freedesktop.UDisks2.PhysicalVolume"]; ok {
+			dev.PhysicalVolume = buildPhysicalVolume(pv)
+			dev.PhysicalVolume.VolumeGroup = vgs[objectPathProperty(pv, "VolumeGroup")]
+		}
+		blocks[path] = dev
+		s.BlockDevices = append(s.BlockDevices, dev)
+	}
+	for _, dev := range s.BlockDevices {
+		if dev.PartitionTable == nil {
+			continue
+		}
+		table := objs[dbus.ObjectPath(dev.Device)]["org.freedesktop.UDisks2.PartitionTable"]
+		if _, ok := table["Partitions"]; !ok {
+			// udisks before 2.7.2 has no Partitions property, the partitions
+			// only refer to their table
+			for _, part := range s.BlockDevices {
+				if part.Partition != nil && part.Partition.Table == dev.Device {
+					dev.PartitionTable.Partitions = append(dev.PartitionTable.Partitions, part.Partition)
+				}
+			}
+			sort.Slice(dev.PartitionTable.Partitions, func(i, j int) bool {
+				return dev.PartitionTable.Partitions[i].Number < dev.PartitionTable.Partitions[j].Number
+			})
+			continue
+		}
+		var partitions []dbus.ObjectPath
+		prop(table, "Partitions", &partitions)
+		for _, p := range partitions {
+			if part, ok := blocks[p]; ok && part.Partition != nil {
+				dev.PartitionTable.Partitions = append(dev.PartitionTable.Partitions, part.Partition)
+			}
+		}
+	}
+	return s
+}
+
+func buildDrive(path dbus.ObjectPath, ifaces map[string]map[string]dbus.Variant) *Drive {
+	props := ifaces["org.freedesktop.UDisks2.Drive"]
+	drv := &Drive{Path: string(path)}
+	prop(props, "Vendor", &drv.Vendor)
+	prop(props, "Serial", &drv.Serial)
+	prop(props, "Model", &drv.Model)
+	prop(props, "Revision", &drv.Revision)
+	prop(props, "WWN", &drv.WWN)
+	prop(props, "Id", &drv.Id)
+	prop(props, "ConnectionBus", &drv.ConnectionBus)
+	prop(props, "Seat", &drv.Seat)
+	prop(props, "SiblingId", &drv.SiblingId)
+	prop(props, "MediaRemovable", &drv.MediaRemovable)
+	prop(props, "MediaAvailable", &drv.MediaAvailable)
+	prop(props, "Ejectable", &drv.Ejectable)
+	prop(props, "Removable", &drv.Removable)
+	prop(props, "Size", &drv.Size)
+	prop(props, "CanPowerOff", &drv.CanPowerOff)
+	prop(props, "RotationRate", &drv.RotationRate)
+	drv.Rotational = drv.RotationRate != 0
+	timeProperty(props, "TimeDetected", &drv.TimeDetected)
+	timeProperty(props, "TimeMediaDetected", &drv.TimeMediaDetected)
+	prop(props, "SortKey", &drv.SortKey)
+	prop(props, "MediaChangeDetected", &drv.MediaChangeDetected)
+	drv.Configuration = buildDriveConfiguration(props)
+	prop(props, "Media", &drv.Media)
+	prop(props, "MediaCompatibility", &drv.MediaCompatibility)
+	prop(props, "Optical", &drv.Optical)
+	prop(props, "OpticalBlank", &drv.OpticalBlank)
+	prop(props, "OpticalNumTracks", &drv.OpticalNumTracks)
+	prop(props, "OpticalNumAudioTracks", &drv.OpticalNumAudioTracks)
+	prop(props, "OpticalNumDataTracks", &drv.OpticalNumDataTracks)
+	prop(props, "OpticalNumSessions", &drv.OpticalNumSessions)
+	if ata, ok := ifaces["org.freedesktop.UDisks2.Drive.Ata"]; ok {
+		drv.Ata = buildAta(ata)
+	}
+	if nvme, ok := ifaces["org.freedesktop.UDisks2.NVMe.Controller"]; ok {
+		drv.NVMeController = buildNVMeController(nvme)
+	}
+	return drv
+}
+
+func buildBlockDevice(path dbus.ObjectPath, ifaces map[string]map[string]dbus.Variant) *BlockDevice {
+	props := ifaces["org.freedesktop.UDisks2.Block"]
+	dev := &BlockDevice{Device: string(path)}
+	prop(props, "IdUUID", &dev.UUID)
+	prop(props, "Id", &dev.Id)
+	prop(props, "IdUsage", &dev.IdUsage)
+	prop(props, "IdLabel", &dev.IdLabel)
+	prop(props, "IdType", &dev.IdType)
+	prop(props, "IdVersion", &dev.IdVersion)
+	byteStringProperty(props, "Device", &dev.DeviceFile)
+	byteStringProperty(props, "PreferredDevice", &dev.PreferredDevice)
+	byteStringArrayProperty(props, "Symlinks", &dev.Symlinks)
+	prop(props, "DeviceNumber", &dev.DeviceNumber)
+	prop(props, "Size", &dev.Size)
+	prop(props, "ReadOnly", &dev.ReadOnly)
+	prop(props, "HintPartitionable", &dev.HintPartitionable)
+	prop(props, "HintSystem", &dev.HintSystem)
+	prop(props, "HintIgnore", &dev.HintIgnore)
+	prop(props, "HintAuto", &dev.HintAuto)
+	prop(props, "HintName", &dev.HintName)
+	prop(props, "HintIconName", &dev.HintIconName)
+	prop(props, "HintSymbolicIconName", &dev.HintSymbolicIconName)
+	dev.MDRaid = string(objectPathProperty(props, "MDRaid"))
+	dev.MDRaidMember = string(objectPathProperty(props, "MDRaidMember"))
+	storeProperty(props, "Configuration", &dev.Configuration)
+	prop(props, "UserspaceMountOptions", &dev.UserspaceMountOptions)
+	if fs, ok := ifaces["org.freedesktop.UDisks2.Filesystem"]; ok {
+		dev.Filesystems = append(dev.Filesystems, buildFilesystem(fs))
+	}
+	if pt, ok := ifaces["org.freedesktop.UDisks2.PartitionTable"]; ok {
+		dev.PartitionTable = buildPartitionTable(pt)
+	}
+	if p, ok := ifaces["org.freedesktop.UDisks2.Partition"]; ok {
+		dev.Partition = buildPartition(path, p)
+	}
+	if ns, ok := ifaces["org.freedesktop.UDisks2.NVMe.Namespace"]; ok {
+		dev.NVMeNamespace = buildNVMeNamespace(ns)
+	}
+	if l, ok := ifaces["org.freedesktop.UDisks2.Loop"]; ok {
+		dev.Loop = buildLoop(l)
+	}
+	if sw, ok := ifaces["org.freedesktop.UDisks2.Swapspace"]; ok {
+		dev.Swapspace = buildSwapspace(sw)
+	}
+	return dev
+}
+
+func buildFilesystem(props map[string]dbus.Variant) Filesystem {
+	fs := Filesystem{}
+	byteStringArrayProperty(props, "MountPoints", &fs.MountPoints)
+	if len(fs.MountPoints) == 0 {
+		fs.MountPoints = nil
+	}
+	prop(props, "Size", &fs.Size)
+	return fs
+}
+
+func buildCryptoBackingDevice(path dbus.ObjectPath, props map[string]dbus.Variant) *CryptoBackingDevice {
+	cbd := &CryptoBackingDevice{Path: string(path)}
+	prop(props, "HintEncryptionType", &cbd.HintEncryptionType)
+	prop(props, "MetadataSize", &cbd.MetadataSize)
+	cbd.CleartextDevicePath = string(objectPathProperty(props, "CleartextDevice"))
+	return cbd
+}
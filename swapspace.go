@@ -0,0 +1,73 @@
+package udisks
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Swapspace is the org.freedesktop.UDisks2.Swapspace interface of a block
+// device holding swap
+type Swapspace struct {
+	Active bool
+}
+
+func buildSwapspace(props map[string]dbus.Variant) *Swapspace {
+	sw := &Swapspace{}
+	prop(props, "Active", &sw.Active)
+	return sw
+}
+
+// StartSwapspace activates the swap space on the block device
+func (c *Client) StartSwapspace(b *BlockDevice) error {
+	return c.StartSwapspaceContext(context.Background(), b)
+}
+
+func (c *Client) StartSwapspaceContext(ctx context.Context, b *BlockDevice) error {
+	err := c.swapspaceCall(ctx, b, "Start")
+	if err == nil && b.Swapspace != nil {
+		b.Swapspace.Active = true
+	}
+	return err
+}
+
+// StopSwapspace deactivates the swap space on the block device
+func (c *Client) StopSwapspace(b *BlockDevice) error {
+	return c.StopSwapspaceContext(context.Background(), b)
+}
+
+func (c *Client) StopSwapspaceContext(ctx context.Context, b *BlockDevice) error {
+	err := c.swapspaceCall(ctx, b, "Stop")
+	if err == nil && b.Swapspace != nil {
+		b.Swapspace.Active = false
+	}
+	return err
+}
+
+func (c *Client) SetSwapspaceLabel(b *BlockDevice, label string) error {
+	return c.SetSwapspaceLabelContext(context.Background(), b, label)
+}
+
+func (c *Client) SetSwapspaceLabelContext(ctx context.Context, b *BlockDevice, label string) error {
+	err := c.swapspaceCall(ctx, b, "SetLabel", label)
+	if err == nil {
+		b.IdLabel = label
+	}
+	return err
+}
+
+func (c *Client) SetSwapspaceUUID(b *BlockDevice, uuid string) error {
+	return c.SetSwapspaceUUIDContext(context.Background(), b, uuid)
+}
+
+func (c *Client) SetSwapspaceUUIDContext(ctx context.Context, b *BlockDevice, uuid string) error {
+	err := c.swapspaceCall(ctx, b, "SetUUID", uuid)
+	if err == nil {
+		b.UUID = uuid
+	}
+	return err
+}
+
+func (c *Client) swapspaceCall(ctx context.Context, b *BlockDevice, method string, args ...interface{}) error {
+	return c.call(ctx, dbus.ObjectPath(b.Device), "org.freedesktop.UDisks2.Swapspace."+method, append(args, defaultOptions())...).Err
+}
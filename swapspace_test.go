@@ -0,0 +1,57 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestStartStopSwapspace(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb2", IdUsage: "other", IdType: "swap", Swapspace: udiskstest.Props{}})
+	b := blockDevice(t, c, p)
+	if b.Swapspace == nil || b.Swapspace.Active {
+		t.Fatalf("unexpected swap space %+v", b.Swapspace)
+	}
+
+	if err := c.StartSwapspace(b); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Swapspace.Active || !blockDevice(t, c, p).Swapspace.Active {
+		t.Error("swap space not active after StartSwapspace")
+	}
+	if err := c.StartSwapspace(b); !errors.Is(err, udisks.ErrFailed) {
+		t.Errorf("second StartSwapspace returned %v, want ErrFailed", err)
+	}
+	if err := c.StopSwapspace(b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Swapspace.Active || blockDevice(t, c, p).Swapspace.Active {
+		t.Error("swap space still active after StopSwapspace")
+	}
+}
+
+func TestSetSwapspaceLabelUUID(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb2", IdUsage: "other", IdType: "swap", Swapspace: udiskstest.Props{}})
+	b := blockDevice(t, c, p)
+
+	if err := c.SetSwapspaceLabel(b, "swap"); err != nil {
+		t.Fatal(err)
+	}
+	if b.IdLabel != "swap" || blockDevice(t, c, p).IdLabel != "swap" {
+		t.Errorf("label %q after SetSwapspaceLabel, want swap", b.IdLabel)
+	}
+	const uuid = "5d3c1f0a-8e2b-4c6d-a1f7-9b0e2d4c6a85"
+	if err := c.SetSwapspaceUUID(b, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if b.UUID != uuid || blockDevice(t, c, p).UUID != uuid {
+		t.Errorf("UUID %q after SetSwapspaceUUID, want %s", b.UUID, uuid)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Swapspace", "SetLabel"); len(calls) != 1 || len(calls[0].Args) != 2 {
+		t.Errorf("unexpected SetLabel calls %v", calls)
+	}
+}
@@ -0,0 +1,56 @@
+package udisks_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+func TestDefaultTimeout(t *testing.T) {
+	srv, c := newTestClient(t, udisks.WithTimeout(50*time.Millisecond))
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", Filesystem: udiskstest.Props{}})
+	b := blockDevice(t, c, p)
+	srv.Handle("org.freedesktop.UDisks2.Filesystem", "Mount", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return []interface{}{"/mnt"}, nil
+	})
+
+	if _, err := c.Mount(b, udisks.MountOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Mount returned %v, want context.DeadlineExceeded", err)
+	}
+	// the deadline of the context replaces the default timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.MountContext(ctx, b, udisks.MountOptions{}); err != nil {
+		t.Errorf("MountContext with a longer deadline returned %v", err)
+	}
+}
+
+func TestDefaultTimeoutErase(t *testing.T) {
+	srv, c := newTestClient(t, udisks.WithTimeout(50*time.Millisecond))
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb"})
+	b := blockDevice(t, c, p)
+	srv.Handle("org.freedesktop.UDisks2.Block", "Format", func(dbus.ObjectPath, []interface{}) ([]interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	})
+
+	if err := c.Format(b, "ext4", udisks.FormatOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Format returned %v, want context.DeadlineExceeded", err)
+	}
+	// erasing is not cut short by the default timeout
+	if err := c.Format(b, "ext4", udisks.FormatOptions{Erase: "zero"}); err != nil {
+		t.Errorf("Format with erase returned %v", err)
+	}
+	// but still by the deadline of the context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.FormatContext(ctx, b, "ext4", udisks.FormatOptions{Erase: "zero"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FormatContext returned %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -1,22 +1,44 @@
+// Package udisks gives high level access to drives and block devices through
+// the UDisks2 D-Bus service.
+//
+// Every Client method that talks to udisksd has an XxxContext variant, the
+// context-aware form of Xxx. Xxx calls it with context.Background().
 package udisks
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/godbus/dbus/v5"
-
-	"github.com/godbus/dbus/v5/introspect"
 )
 
 type Client struct {
-	conn *dbus.Conn
+	conn    *dbus.Conn
+	timeout time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets the default timeout of every D-Bus call made by the client.
+// It applies when the context passed to a method has no deadline of its own.
+// Operations that may run for hours, such as erasing a drive or checking a file
+// system, ignore it and are only bound by their context.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
 }
 
 type Drive struct {
-	Vendor         string
-	Model          string
-	Serial         string
+	Path   string
+	Vendor string
+	Model  string
+	Serial string
+	// Revision is the firmware revision
+	Revision       string
+	WWN            string
 	Id             string
 	MediaRemovable bool
 	Ejectable      bool
@@ -27,9 +49,45 @@ type Drive struct {
 	Removable      bool
 	Size           uint64
 	CanPowerOff    bool
-	NVMeController *NVMeController
-	Ata            *Ata
+	// Rotational is set for spinning disks, RotationRate is in rpm, -1 if
+	// the rate is unknown and 0 for non-rotating media
+	Rotational          bool
+	RotationRate        int32
+	TimeDetected        time.Time
+	TimeMediaDetected   time.Time
+	SortKey             string
+	MediaChangeDetected bool
+	Configuration       DriveConfiguration
+	// Media is the kind of media inserted, e.g. "optical_dvd" or "flash_sd"
+	Media string
+	// MediaCompatibility lists the kinds of media the drive accepts
+	MediaCompatibility    []string
+	Optical               bool
+	OpticalBlank          bool
+	OpticalNumTracks      uint32
+	OpticalNumAudioTracks uint32
+	OpticalNumDataTracks  uint32
+	OpticalNumSessions    uint32
+	NVMeController        *NVMeController
+	Ata                   *Ata
 }
+
+// PowerOffReason gives a best-effort hint why CanPowerOff is not set, guessed
+// from the connection bus and the Removable flag. udisksd decides from sysfs
+// attributes that are not exported, so the hint may not be the actual cause.
+// It returns "" when the drive can be powered off.
+func (d *Drive) PowerOffReason() string {
+	switch {
+	case d.CanPowerOff:
+		return ""
+	case d.ConnectionBus != "usb" && d.ConnectionBus != "ieee1394":
+		return "drive is not connected through USB or IEEE 1394"
+	case !d.Removable:
+		return "drive is not removable"
+	}
+	return "drive is not detachable"
+}
+
 type BlockDevices []*BlockDevice
 
 func (b BlockDevices) ByDevice(device string) *BlockDevice {
@@ -42,16 +100,49 @@ func (b BlockDevices) ByDevice(device string) *BlockDevice {
 }
 
 type BlockDevice struct {
-	UUID                string
-	Device              string
-	Id                  string
-	IdUsage             string
-	IdLabel             string
-	IdType              string
-	Drive               *Drive
-	Filesystems         []Filesystem
-	Symlinks            []string
-	CryptoBackingDevice *CryptoBackingDevice
+	UUID   string
+	Device string
+	// DeviceFile is the special device file such as /dev/sda1, PreferredDevice
+	// the name to present to users, e.g. /dev/mapper/luks-...
+	DeviceFile      string
+	PreferredDevice string
+	// DeviceNumber is the dev_t of the device
+	DeviceNumber uint64
+	Size         uint64
+	ReadOnly     bool
+	Id           string
+	IdUsage      string
+	IdLabel      string
+	IdType       string
+	IdVersion    string
+	// HintSystem is set for devices that are not user removable, HintIgnore
+	// for devices that should be hidden from users
+	HintPartitionable    bool
+	HintSystem           bool
+	HintIgnore           bool
+	HintAuto             bool
+	HintName             string
+	HintIconName         string
+	HintSymbolicIconName string
+	// MDRaid is the object path of the RAID array the device is,
+	// MDRaidMember the one it is a member of
+	MDRaid                string
+	MDRaidMember          string
+	Configuration         []ConfigurationItem
+	UserspaceMountOptions []string
+	Drive                 *Drive
+	Filesystems           []Filesystem
+	Symlinks              []string
+	CryptoBackingDevice   *CryptoBackingDevice
+	PartitionTable        *PartitionTable
+	Partition             *Partition
+	NVMeNamespace         *NVMeNamespace
+	Loop                  *Loop
+	Swapspace             *Swapspace
+	// LogicalVolume is set for the block device of an active LVM2 volume,
+	// PhysicalVolume for devices used by a volume group
+	LogicalVolume  *LogicalVolume
+	PhysicalVolume *PhysicalVolume
 }
 
 func (b *BlockDevice) IsMounted() bool {
@@ -78,23 +169,59 @@ type Filesystem struct {
 func (f Filesystem) IsMounted() bool {
 	return len(f.MountPoints) > 0
 }
-func NewClient() (*Client, error) {
-	c := &Client{}
+func NewClient(opts ...Option) (*Client, error) {
 	conn, err := dbus.ConnectSystemBus()
 	if err != nil {
 		return nil, err
 	}
-	c.conn = conn
 
-	return c, nil
+	return NewClientWithConn(conn, opts...), nil
+}
+
+// NewClientWithConn returns a client using an already established connection,
+// for instance to a private bus running a fake UDisks service
+func NewClientWithConn(conn *dbus.Conn, opts ...Option) *Client {
+	c := &Client{conn: conn}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// PowerOff unmounts all blockdevices on the device, stops swap on them, lock any unlocked encrypted containers and then powers off the device
+func (c *Client) PowerOff(d *Drive) error {
+	return c.PowerOffContext(context.Background(), d)
 }
 
-// PowerOff unmounts all blockdevices on the device, lock any unlocked encrypted containers and then powers off the device
-func (c Client) PowerOff(d *Drive) error {
+func (c *Client) PowerOffContext(ctx context.Context, d *Drive) error {
 	if !d.CanPowerOff {
-		return ErrPowerOffNotSupported
+		return fmt.Errorf("%w: %s", ErrPowerOffNotSupported, d.PowerOffReason())
+	}
+	if err := c.tearDownDrive(ctx, d); err != nil {
+		return err
+	}
+	return c.call(ctx, dbus.ObjectPath(d.Path), "org.freedesktop.UDisks2.Drive.PowerOff", defaultOptions()).Err
+}
+
+// Eject unmounts all blockdevices on the drive, stops swap on them, locks any unlocked encrypted containers and then ejects the media
+func (c *Client) Eject(d *Drive) error {
+	return c.EjectContext(context.Background(), d)
+}
+
+func (c *Client) EjectContext(ctx context.Context, d *Drive) error {
+	if !d.Ejectable {
+		return ErrEjectNotSupported
+	}
+	if err := c.tearDownDrive(ctx, d); err != nil {
+		return err
 	}
-	blocks, err := c.BlockDevices()
+	return c.call(ctx, dbus.ObjectPath(d.Path), "org.freedesktop.UDisks2.Drive.Eject", defaultOptions()).Err
+}
+
+// tearDownDrive unmounts the block devices on the drive, stops swap on them and
+// locks the encrypted containers on it
+func (c *Client) tearDownDrive(ctx context.Context, d *Drive) error {
+	blocks, err := c.BlockDevicesContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -103,175 +230,102 @@ func (c Client) PowerOff(d *Drive) error {
 		if b.CryptoBackingDevice != nil {
 			if b.CryptoBackingDevice.CleartextDevicePath != "" {
 				cryptoDrive := blocks.ByDevice(b.CryptoBackingDevice.Path)
-				if cryptoDrive.Drive != nil && cryptoDrive.Drive.Id == d.Id {
-					if b.IsMounted() {
-						if err := c.UnmountBlockDevice(b.Device); err != nil {
-							return fmt.Errorf("%w:  %w", ErrUnmountFailed, err)
-						}
+				if cryptoDrive != nil && cryptoDrive.Drive != nil && cryptoDrive.Drive.Id == d.Id {
+					if err := c.deactivate(ctx, b); err != nil {
+						return err
 					}
-					if err := c.LockCryptoDevice(b.CryptoBackingDevice.Path); err != nil {
-						return fmt.Errorf("%w:  %w", ErrUnmountFailed, err)
+					if err := c.LockCryptoDeviceContext(ctx, b.CryptoBackingDevice.Path); err != nil {
+						return fmt.Errorf("%w: %w", ErrLockingFailed, err)
 					}
 				}
 			}
 		} else {
 			if b.Drive != nil && b.Drive.Id == d.Id {
-				if len(b.Filesystems) > 0 {
-					if err := c.UnmountBlockDevice(b.Id); err != nil {
-						return fmt.Errorf("%w: %w", ErrUnmountFailed, err)
-					}
+				if err := c.deactivate(ctx, b); err != nil {
+					return err
 				}
 			}
 		}
 	}
-	path := "/org/freedesktop/UDisks2/drives/" + strings.ReplaceAll(d.Id, "-", "_")
-	powerOffObj := c.conn.Object("org.freedesktop.UDisks2", dbus.ObjectPath(path))
-	opt := map[string]interface{}{
-		"auth.no_user_interaction": true,
+	return nil
+}
+
+// deactivate unmounts the block device and stops the swap space on it
+func (c *Client) deactivate(ctx context.Context, b *BlockDevice) error {
+	if b.IsMounted() {
+		if err := c.UnmountBlockDeviceContext(ctx, b.Device); err != nil {
+			return fmt.Errorf("%w: %w", ErrUnmountFailed, err)
+		}
+	}
+	if b.Swapspace != nil && b.Swapspace.Active {
+		if err := c.StopSwapspaceContext(ctx, b); err != nil {
+			return fmt.Errorf("%w: %w", ErrSwapoffFailed, err)
+		}
 	}
-	return powerOffObj.Call("org.freedesktop.UDisks2.Drive.PowerOff", 0, &opt).Err
+	return nil
 }
 func (c *Client) LockCryptoDevice(path string) error {
-	opt := map[string]interface{}{
-		"auth.no_user_interaction": true,
-	}
-	obj := c.conn.Object("org.freedesktop.UDisks2", dbus.ObjectPath(path))
-	result := obj.Call("org.freedesktop.UDisks2.Encrypted.Lock", 0, opt)
-	return result.Err
+	return c.LockCryptoDeviceContext(context.Background(), path)
+}
+func (c *Client) LockCryptoDeviceContext(ctx context.Context, path string) error {
+	return c.call(ctx, dbus.ObjectPath(path), "org.freedesktop.UDisks2.Encrypted.Lock", defaultOptions()).Err
 }
 func (c *Client) UnmountBlockDevice(path string) error {
-	opt := map[string]interface{}{
-		"auth.no_user_interaction": true,
-	}
-	obj := c.conn.Object("org.freedesktop.UDisks2", dbus.ObjectPath(path))
-	result := obj.Call("org.freedesktop.UDisks2.Filesystem.Unmount", 0, opt)
-	return result.Err
+	return c.UnmountBlockDeviceContext(context.Background(), path)
+}
+func (c *Client) UnmountBlockDeviceContext(ctx context.Context, path string) error {
+	return c.call(ctx, dbus.ObjectPath(path), "org.freedesktop.UDisks2.Filesystem.Unmount", defaultOptions()).Err
 }
 
 // BlockDevices returns the list of all block devices known to UDisks
 func (c *Client) BlockDevices() (BlockDevices, error) {
-	conn := c.conn
-	var list []string
-	var filter map[string]interface{}
-	obj := conn.Object("org.freedesktop.UDisks2", "/org/freedesktop/UDisks2/Manager")
-	err := obj.Call("org.freedesktop.UDisks2.Manager.GetBlockDevices", 0, &filter).Store(&list)
+	return c.BlockDevicesContext(context.Background())
+}
+
+func (c *Client) BlockDevicesContext(ctx context.Context) (BlockDevices, error) {
+	s, err := c.SnapshotContext(ctx)
 	if err != nil {
 		return BlockDevices{}, err
 	}
-
-	bdevs := []*BlockDevice{}
-	for _, bd := range list {
-		dev := &BlockDevice{}
-		bdevs = append(bdevs, dev)
-		obj = conn.Object("org.freedesktop.UDisks2", dbus.ObjectPath(bd))
-		dev.Device = bd
-		stringProperty("org.freedesktop.UDisks2.Block.IdUUID", obj, &dev.UUID)
-		stringProperty("org.freedesktop.UDisks2.Block.Id", obj, &dev.Id)
-		stringProperty("org.freedesktop.UDisks2.Block.IdUsage", obj, &dev.IdUsage)
-		stringProperty("org.freedesktop.UDisks2.Block.IdLabel", obj, &dev.IdLabel)
-		stringProperty("org.freedesktop.UDisks2.Block.IdType", obj, &dev.IdType)
-		stringArrayPropertyFromByte("org.freedesktop.UDisks2.Block.Symlinks", obj, &dev.Symlinks)
-
-		var props map[string]dbus.Variant
-		cbd, err := objGet(conn, "org.freedesktop.UDisks2.Block.CryptoBackingDevice", obj)
-		if err == nil {
-			cbd.Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.UDisks2.Encrypted").Store(&props)
-			if len(props) != 0 {
-				dev.CryptoBackingDevice = &CryptoBackingDevice{
-					Path:               string(cbd.Path()),
-					HintEncryptionType: props["HintEncryptionType"].Value().(string),
-					MetadataSize:       props["MetadataSize"].Value().(uint64),
-				}
-
-				clearPath := props["CleartextDevice"].Value()
-				if val, ok := clearPath.(dbus.ObjectPath); ok && val.IsValid() {
-					dev.CryptoBackingDevice.CleartextDevicePath = string(val)
-				}
-			}
-		}
-
-		dev.Drive, err = c.getDrive(obj)
-
-		obj.Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.UDisks2.Filesystem").Store(&props)
-
-		if len(props) != 0 {
-
-			fs := Filesystem{}
-			va := props["MountPoints"].Value()
-			if va != nil {
-				arr := va.([][]byte)
-				for i := 0; i < len(arr); i++ {
-					mpsa := arr[i]
-					mpa := string(mpsa[0 : len(mpsa)-1])
-
-					fs.MountPoints = append(fs.MountPoints, mpa)
-				}
-			}
-			va = props["Size"].Value()
-			if va != nil {
-				fs.Size = va.(uint64)
-			}
-
-			dev.Filesystems = append(dev.Filesystems, fs)
-		}
-	}
-
-	return bdevs, nil
+	return s.BlockDevices, nil
 }
 
-// Drives returns the list of all block devices known to UDisks
+// Drives returns the list of all drives known to UDisks
 func (c *Client) Drives() ([]*Drive, error) {
-	drives := []*Drive{}
-	node, err := introspect.Call(c.conn.Object("org.freedesktop.UDisks2", "/org/freedesktop/UDisks2/drives"))
-	if err != nil {
-		return drives, err
-	}
+	return c.DrivesContext(context.Background())
+}
 
-	for _, ch := range node.Children {
-		path := "/org/freedesktop/UDisks2/drives/" + ch.Name
-		obj := c.conn.Object("org.freedesktop.UDisks2", dbus.ObjectPath(path))
-		drv, err := c.buildDrive(obj)
-		if err != nil {
-			return drives, err
-		}
-		drives = append(drives, drv)
+func (c *Client) DrivesContext(ctx context.Context) ([]*Drive, error) {
+	s, err := c.SnapshotContext(ctx)
+	if err != nil {
+		return []*Drive{}, err
 	}
-
-	return drives, nil
+	return s.Drives, nil
 }
 
 func (c *Client) DriveById(name string) (*Drive, error) {
-	//we need to replace - with _ to give the correct id
-	path := "/org/freedesktop/UDisks2/drives/" + strings.ReplaceAll(name, "-", "_")
-	obj := c.conn.Object("org.freedesktop.UDisks2", dbus.ObjectPath(path))
-	drv, err := c.buildDrive(obj)
+	return c.DriveByIdContext(context.Background(), name)
+}
+
+func (c *Client) DriveByIdContext(ctx context.Context, name string) (*Drive, error) {
+	s, err := c.SnapshotContext(ctx)
 	if err != nil {
-		return drv, err
+		return nil, err
 	}
-	if drv.Id == "" {
-		return drv, ErrDriveNotFound
+	drv := s.DriveById(name)
+	if drv == nil {
+		return nil, ErrDriveNotFound
 	}
-	return drv, err
+	return drv, nil
 }
 func (c *Client) BlockDevicesOnDrive(id string) ([]*BlockDevice, error) {
-	blocks, err := c.BlockDevices()
+	return c.BlockDevicesOnDriveContext(context.Background(), id)
+}
+
+func (c *Client) BlockDevicesOnDriveContext(ctx context.Context, id string) ([]*BlockDevice, error) {
+	s, err := c.SnapshotContext(ctx)
 	if err != nil {
 		return []*BlockDevice{}, err
 	}
-	blockDevices := make([]*BlockDevice, 0)
-	for _, b := range blocks {
-		if b.CryptoBackingDevice != nil {
-			if b.CryptoBackingDevice.CleartextDevicePath != "" {
-				cryptoDrive := blocks.ByDevice(b.CryptoBackingDevice.Path)
-				if cryptoDrive.Drive != nil && cryptoDrive.Drive.Id == id {
-					blockDevices = append(blockDevices, b)
-				}
-			}
-		} else {
-			if b.Drive != nil && b.Drive.Id == id {
-				blockDevices = append(blockDevices, b)
-			}
-		}
-	}
-	return blockDevices, nil
+	return s.BlockDevicesOnDrive(id), nil
 }
@@ -0,0 +1,368 @@
+package udisks_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+// newTestClient starts a fake udisksd on a private bus and returns it together
+// with a client connected to it. The test is skipped if dbus-daemon is missing.
+func newTestClient(t *testing.T, opts ...udisks.Option) (*udiskstest.Server, *udisks.Client) {
+	t.Helper()
+	srv, err := udiskstest.NewServer()
+	if errors.Is(err, udiskstest.ErrNoDaemon) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	conn, err := srv.Conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, udisks.NewClientWithConn(conn, opts...)
+}
+
+// blockDevice returns the block device at path from a fresh snapshot
+func blockDevice(t *testing.T, c *udisks.Client, path dbus.ObjectPath) *udisks.BlockDevice {
+	t.Helper()
+	blocks, err := c.BlockDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := blocks.ByDevice(string(path))
+	if b == nil {
+		t.Fatalf("block device %s not found", path)
+	}
+	return b
+}
+
+// drive returns the drive with the given id from a fresh snapshot
+func drive(t *testing.T, c *udisks.Client, id string) *udisks.Drive {
+	t.Helper()
+	d, err := c.DriveById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func mounted(fs []byte) [][]byte {
+	return [][]byte{append(fs, 0)}
+}
+
+func TestSnapshot(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{
+		Id:     "Vendor-Disk-1234",
+		Vendor: "Vendor",
+		Model:  "Disk",
+		Serial: "1234",
+		Size:   1 << 30,
+		Ata:    udiskstest.Props{"SmartSupported": true, "SmartTemperature": 310.0},
+	})
+	srv.AddBlock(udiskstest.Block{Name: "sda", Drive: drv, Size: 1 << 30})
+	srv.AddBlock(udiskstest.Block{Name: "sda1", Drive: drv, Size: 1 << 29, IdUsage: "filesystem", IdType: "ext4", IdLabel: "data", Filesystem: udiskstest.Props{}})
+	crypt := srv.AddBlock(udiskstest.Block{Name: "sda2", Drive: drv, Size: 1 << 29, IdUsage: "crypto", Encrypted: udiskstest.Props{}, Passphrase: "secret"})
+	srv.AddBlock(udiskstest.Block{Name: "dm-0", IdUsage: "filesystem", Filesystem: udiskstest.Props{}, Props: udiskstest.Props{"CryptoBackingDevice": crypt}})
+	srv.SetProperty(crypt, "org.freedesktop.UDisks2.Encrypted", "CleartextDevice", udiskstest.BlockPath("dm-0"))
+
+	s, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Drives) != 1 || len(s.BlockDevices) != 4 {
+		t.Fatalf("got %d drives and %d block devices, want 1 and 4", len(s.Drives), len(s.BlockDevices))
+	}
+	d := s.DriveById("Vendor-Disk-1234")
+	if d == nil {
+		t.Fatal("drive not found")
+	}
+	if d.Vendor != "Vendor" || d.Model != "Disk" || d.Serial != "1234" || d.Size != 1<<30 {
+		t.Errorf("unexpected drive %+v", d)
+	}
+	if d.Ata == nil || !d.Ata.SmartSupported || d.Ata.SmartTemperature != 310 {
+		t.Errorf("unexpected ATA properties %+v", d.Ata)
+	}
+
+	b := s.BlockDevices.ByDevice(string(udiskstest.BlockPath("sda1")))
+	if b == nil || b.Drive != d {
+		t.Fatalf("sda1 is not linked to its drive: %+v", b)
+	}
+	if b.DeviceFile != "/dev/sda1" || b.IdType != "ext4" || b.IdLabel != "data" || len(b.Filesystems) != 1 {
+		t.Errorf("unexpected block device %+v", b)
+	}
+	cleartext := s.BlockDevices.ByDevice(string(udiskstest.BlockPath("dm-0")))
+	if cleartext == nil || cleartext.CryptoBackingDevice == nil || cleartext.CryptoBackingDevice.Path != string(crypt) {
+		t.Fatalf("dm-0 is not linked to its crypto backing device: %+v", cleartext)
+	}
+	if got := s.BlockDevicesOnDrive("Vendor-Disk-1234"); len(got) != 4 {
+		t.Errorf("BlockDevicesOnDrive returned %d devices, want 4", len(got))
+	}
+}
+
+func TestBlockDeviceProperties(t *testing.T) {
+	srv, c := newTestClient(t)
+	raid := dbus.ObjectPath("/org/freedesktop/UDisks2/mdraid/data")
+	p := srv.AddBlock(udiskstest.Block{Name: "md127", Props: udiskstest.Props{
+		"PreferredDevice":      []byte("/dev/md/data\x00"),
+		"DeviceNumber":         uint64(9<<8 | 127),
+		"HintPartitionable":    false,
+		"HintSystem":           true,
+		"HintIgnore":           true,
+		"HintAuto":             true,
+		"HintName":             "Data",
+		"HintIconName":         "drive-harddisk",
+		"HintSymbolicIconName": "drive-harddisk-symbolic",
+		"MDRaid":               raid,
+		"MDRaidMember":         dbus.ObjectPath("/org/freedesktop/UDisks2/mdraid/root"),
+		"Configuration": []udiskstest.ConfigurationItem{{Type: "fstab", Details: map[string]dbus.Variant{
+			"dir":    dbus.MakeVariant([]byte("/srv/data\x00")),
+			"opts":   dbus.MakeVariant([]byte("noatime\x00")),
+			"passno": dbus.MakeVariant(int32(2)),
+		}}},
+		"UserspaceMountOptions": []string{"x-gvfs-show"},
+	}})
+
+	b := blockDevice(t, c, p)
+	if b.PreferredDevice != "/dev/md/data" || b.DeviceNumber != 9<<8|127 {
+		t.Errorf("got PreferredDevice %q and DeviceNumber %d", b.PreferredDevice, b.DeviceNumber)
+	}
+	if b.HintPartitionable || !b.HintSystem || !b.HintIgnore || !b.HintAuto ||
+		b.HintName != "Data" || b.HintIconName != "drive-harddisk" || b.HintSymbolicIconName != "drive-harddisk-symbolic" {
+		t.Errorf("unexpected hints %+v", b)
+	}
+	if b.MDRaid != string(raid) || b.MDRaidMember != "/org/freedesktop/UDisks2/mdraid/root" {
+		t.Errorf("got MDRaid %q and MDRaidMember %q", b.MDRaid, b.MDRaidMember)
+	}
+	if len(b.Configuration) != 1 || b.Configuration[0].Type != "fstab" {
+		t.Fatalf("unexpected configuration %+v", b.Configuration)
+	}
+	if item := b.Configuration[0]; item.Detail("dir") != "/srv/data" || item.Detail("opts") != "noatime" || item.Detail("passno") != "" || item.Detail("fsname") != "" {
+		t.Errorf("unexpected fstab details %v", item.Details)
+	}
+	if len(b.UserspaceMountOptions) != 1 || b.UserspaceMountOptions[0] != "x-gvfs-show" {
+		t.Errorf("got UserspaceMountOptions %v", b.UserspaceMountOptions)
+	}
+
+	b = blockDevice(t, c, srv.AddBlock(udiskstest.Block{Name: "sdc"}))
+	if b.MDRaid != "" || b.MDRaidMember != "" || len(b.Configuration) != 0 {
+		t.Errorf("unexpected defaults %+v", b)
+	}
+}
+
+func TestDrivesAndBlockDevices(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "Disk-A"})
+	srv.AddDrive(udiskstest.Drive{Id: "Disk-B"})
+	srv.AddBlock(udiskstest.Block{Name: "sda", Drive: drv})
+	srv.AddBlock(udiskstest.Block{Name: "loop0"})
+
+	drives, err := c.Drives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drives) != 2 {
+		t.Errorf("got %d drives, want 2", len(drives))
+	}
+	blocks, err := c.BlockDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 {
+		t.Errorf("got %d block devices, want 2", len(blocks))
+	}
+	onDrive, err := c.BlockDevicesOnDrive("Disk-A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onDrive) != 1 || onDrive[0].Device != string(udiskstest.BlockPath("sda")) {
+		t.Errorf("unexpected block devices on Disk-A: %v", onDrive)
+	}
+	if _, err := c.DriveById("Disk-C"); !errors.Is(err, udisks.ErrDriveNotFound) {
+		t.Errorf("DriveById of a missing drive returned %v, want ErrDriveNotFound", err)
+	}
+}
+
+func TestMountUnmount(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", IdType: "ext4", IdLabel: "usb", Filesystem: udiskstest.Props{}})
+	b := blockDevice(t, c, p)
+
+	mountPath, err := c.Mount(b, udisks.MountOptions{Options: "ro,noexec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mountPath != "/run/media/udiskstest/usb" {
+		t.Errorf("mount path %q, want /run/media/udiskstest/usb", mountPath)
+	}
+	if !b.IsMounted() {
+		t.Error("block device not mounted after Mount")
+	}
+	calls := srv.CallsTo("org.freedesktop.UDisks2.Filesystem", "Mount")
+	if len(calls) != 1 {
+		t.Fatalf("got %d Mount calls, want 1", len(calls))
+	}
+	if opts := calls[0].Args[0].(map[string]dbus.Variant); opts["options"].Value() != "ro,noexec" {
+		t.Errorf("mount options %v, want ro,noexec", opts["options"])
+	}
+
+	if err := c.UnmountBlockDevice(b.Device); err != nil {
+		t.Fatal(err)
+	}
+	if blockDevice(t, c, p).IsMounted() {
+		t.Error("block device still mounted after UnmountBlockDevice")
+	}
+	if err := c.UnmountBlockDevice(b.Device); !errors.Is(err, udisks.ErrNotMounted) {
+		t.Errorf("second unmount returned %v, want ErrNotMounted", err)
+	}
+}
+
+func TestMountAlreadyMounted(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", Filesystem: udiskstest.Props{"MountPoints": mounted([]byte("/mnt"))}})
+
+	_, err := c.Mount(blockDevice(t, c, p), udisks.MountOptions{})
+	if !errors.Is(err, udisks.ErrAlreadyMounted) {
+		t.Fatalf("Mount returned %v, want ErrAlreadyMounted", err)
+	}
+	var e *udisks.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Mount returned %T, want *udisks.Error", err)
+	}
+	if e.Name != "org.freedesktop.UDisks2.Error.AlreadyMounted" || e.Path != string(p) {
+		t.Errorf("unexpected error %+v", e)
+	}
+}
+
+func TestUnlockLock(t *testing.T) {
+	srv, c := newTestClient(t)
+	p := srv.AddBlock(udiskstest.Block{Name: "sdc1", IdUsage: "crypto", IdType: "crypto_LUKS", Encrypted: udiskstest.Props{}, Passphrase: "secret"})
+	b := blockDevice(t, c, p)
+
+	if _, err := c.Unlock(b, udisks.UnlockOptions{Passphrase: []byte("wrong")}); !errors.Is(err, udisks.ErrFailed) {
+		t.Fatalf("Unlock with a wrong passphrase returned %v, want ErrFailed", err)
+	}
+	cleartext, err := c.Unlock(b, udisks.UnlockOptions{Passphrase: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleartext.CryptoBackingDevice == nil || cleartext.CryptoBackingDevice.Path != string(p) {
+		t.Fatalf("cleartext device is not linked to %s: %+v", p, cleartext)
+	}
+	if _, err := c.Unlock(b, udisks.UnlockOptions{Passphrase: []byte("secret")}); !errors.Is(err, udisks.ErrAlreadyUnlocked) {
+		t.Errorf("second Unlock returned %v, want ErrAlreadyUnlocked", err)
+	}
+
+	if err := c.LockCryptoDevice(b.Device); err != nil {
+		t.Fatal(err)
+	}
+	if srv.HasObject(dbus.ObjectPath(cleartext.Device)) {
+		t.Error("cleartext device still exported after LockCryptoDevice")
+	}
+	if err := c.LockCryptoDevice(b.Device); !errors.Is(err, udisks.ErrNotUnlocked) {
+		t.Errorf("second LockCryptoDevice returned %v, want ErrNotUnlocked", err)
+	}
+}
+
+func TestPowerOff(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "USB-Stick", CanPowerOff: true})
+	fs := srv.AddBlock(udiskstest.Block{Name: "sdd1", Drive: drv, IdUsage: "filesystem", Filesystem: udiskstest.Props{"MountPoints": mounted([]byte("/mnt"))}})
+	crypt := srv.AddBlock(udiskstest.Block{Name: "sdd2", Drive: drv, IdUsage: "crypto", Encrypted: udiskstest.Props{}, Passphrase: "secret"})
+	if _, err := c.Unlock(blockDevice(t, c, crypt), udisks.UnlockOptions{Passphrase: []byte("secret")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.PowerOff(drive(t, c, "USB-Stick")); err != nil {
+		t.Fatal(err)
+	}
+	if srv.HasObject(drv) {
+		t.Error("drive still exported after PowerOff")
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Filesystem", "Unmount"); len(calls) != 1 || calls[0].Path != fs {
+		t.Errorf("unexpected Unmount calls %v", calls)
+	}
+	if calls := srv.CallsTo("org.freedesktop.UDisks2.Encrypted", "Lock"); len(calls) != 1 || calls[0].Path != crypt {
+		t.Errorf("unexpected Lock calls %v", calls)
+	}
+}
+
+func TestPowerOffNotSupported(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "Internal-Disk"})
+
+	if err := c.PowerOff(drive(t, c, "Internal-Disk")); !errors.Is(err, udisks.ErrPowerOffNotSupported) {
+		t.Errorf("PowerOff returned %v, want ErrPowerOffNotSupported", err)
+	}
+}
+
+func TestDriveProperties(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddDrive(udiskstest.Drive{Id: "Vendor-Disk-1234", Props: udiskstest.Props{
+		"Revision":          "FW1.0",
+		"WWN":               "0x5000c500a1b2c3d4",
+		"TimeDetected":      uint64(1700000000123456),
+		"TimeMediaDetected": uint64(0),
+		"SortKey":           "01hotplug/1700000000123456",
+		"Configuration": map[string]dbus.Variant{
+			"ata-pm-standby":          dbus.MakeVariant(int32(241)),
+			"ata-write-cache-enabled": dbus.MakeVariant(false),
+		},
+	}})
+
+	d := drive(t, c, "Vendor-Disk-1234")
+	if d.Revision != "FW1.0" || d.WWN != "0x5000c500a1b2c3d4" || d.SortKey != "01hotplug/1700000000123456" {
+		t.Errorf("unexpected drive %+v", d)
+	}
+	if want := time.Unix(1700000000, 123456000); !d.TimeDetected.Equal(want) {
+		t.Errorf("TimeDetected is %v, want %v", d.TimeDetected, want)
+	}
+	if !d.TimeMediaDetected.IsZero() {
+		t.Errorf("TimeMediaDetected is %v, want the zero time for 0", d.TimeMediaDetected)
+	}
+	cfg := d.Configuration
+	if cfg.AtaPmStandby == nil || *cfg.AtaPmStandby != 241 || cfg.AtaWriteCacheEnabled == nil || *cfg.AtaWriteCacheEnabled || cfg.AtaAPMLevel != nil {
+		t.Errorf("unexpected configuration %+v", cfg)
+	}
+}
+
+func TestDriveRotationRate(t *testing.T) {
+	for _, r := range []struct {
+		rate       int32
+		rotational bool
+	}{
+		{-1, true},
+		{0, false},
+		{7200, true},
+	} {
+		srv, c := newTestClient(t)
+		srv.AddDrive(udiskstest.Drive{Id: "Disk", Props: udiskstest.Props{"RotationRate": r.rate}})
+		d := drive(t, c, "Disk")
+		if d.RotationRate != r.rate || d.Rotational != r.rotational {
+			t.Errorf("RotationRate %d: got %d and Rotational %v, want %v", r.rate, d.RotationRate, d.Rotational, r.rotational)
+		}
+	}
+}
+
+func TestPowerOffReason(t *testing.T) {
+	for _, d := range []struct {
+		drive  udisks.Drive
+		reason string
+	}{
+		{udisks.Drive{CanPowerOff: true}, ""},
+		{udisks.Drive{ConnectionBus: "sdio", Removable: true}, "drive is not connected through USB or IEEE 1394"},
+		{udisks.Drive{ConnectionBus: "usb"}, "drive is not removable"},
+		{udisks.Drive{ConnectionBus: "ieee1394", Removable: true}, "drive is not detachable"},
+	} {
+		if got := d.drive.PowerOffReason(); got != d.reason {
+			t.Errorf("PowerOffReason of %+v returned %q, want %q", d.drive, got, d.reason)
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package udiskstest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Drive describes a fake drive. Props are merged over the defaults of the
+// org.freedesktop.UDisks2.Drive interface. Ata and NVMeController are exported
+// as org.freedesktop.UDisks2.Drive.Ata and org.freedesktop.UDisks2.NVMe.Controller
+// when not nil. SmartAttributes are returned by Drive.Ata.SmartGetAttributes.
+type Drive struct {
+	Id              string
+	Vendor          string
+	Model           string
+	Serial          string
+	Size            uint64
+	CanPowerOff     bool
+	Ejectable       bool
+	Removable       bool
+	Props           Props
+	Ata             Props
+	NVMeController  Props
+	SmartAttributes []SmartAttribute
+}
+
+// SmartAttribute is an entry of the a(ysqiiixia{sv}) reply of
+// Drive.Ata.SmartGetAttributes
+type SmartAttribute struct {
+	ID         uint8
+	Name       string
+	Flags      uint16
+	Value      int32
+	Worst      int32
+	Threshold  int32
+	Pretty     int64
+	PrettyUnit int32
+	Expansion  map[string]dbus.Variant
+}
+
+// Block describes a fake block device. Props are merged over the defaults of
+// the org.freedesktop.UDisks2.Block interface; the other interfaces are only
+// exported when not nil.
+type Block struct {
+	// Name is the kernel name such as "sda1"
+	Name           string
+	Drive          dbus.ObjectPath
+	Size           uint64
+	IdUsage        string
+	IdType         string
+	IdUUID         string
+	IdLabel        string
+	Props          Props
+	Filesystem     Props
+	Partition      Props
+	PartitionTable Props
+	Encrypted      Props
+	NVMeNamespace  Props
+	Loop           Props
+	Swapspace      Props
+	// Passphrase is the secret accepted by Encrypted.Unlock, either as
+	// passphrase or as keyfile_contents
+	Passphrase string
+	// Cleartext is the device exported when the block is unlocked. A plain
+	// ext4 file system is used when nil.
+	Cleartext *Block
+}
+
+// LBAFormat is an entry of the a(qqy) LBAFormats property of
+// org.freedesktop.UDisks2.NVMe.Namespace
+type LBAFormat struct {
+	DataSize            uint16
+	MetadataSize        uint16
+	RelativePerformance uint8
+}
+
+// ConfigurationItem is an entry of the a(sa{sv}) configuration properties,
+// such as ("fstab", {"dir": ...})
+type ConfigurationItem struct {
+	Type    string
+	Details map[string]dbus.Variant
+}
+
+// MDRaid describes a fake RAID array. Members get their MDRaidMember property
+// set; a running array also exports an md block device. Props are merged over
+// the defaults of the org.freedesktop.UDisks2.MDRaid interface.
+type MDRaid struct {
+	UUID    string
+	Name    string
+	Level   string
+	Members []dbus.ObjectPath
+	Size    uint64
+	Running bool
+	Props   Props
+}
+
+// MDRaidDevice is an entry of the a(oiasta{sv}) ActiveDevices property of
+// org.freedesktop.UDisks2.MDRaid
+type MDRaidDevice struct {
+	Block         dbus.ObjectPath
+	Slot          int32
+	State         []string
+	NumReadErrors uint64
+	Expansion     map[string]dbus.Variant
+}
+
+// VolumeGroup describes a fake LVM2 volume group. Members are exported with the
+// org.freedesktop.UDisks2.PhysicalVolume interface and make up its size.
+type VolumeGroup struct {
+	Name    string
+	Members []dbus.ObjectPath
+	Props   Props
+}
+
+// LogicalVolume describes a fake logical volume of the volume group at
+// VolumeGroup. Type defaults to "block" and Layout to "linear"; volumes other
+// than thin and VDO volumes take their size from the free space of the group.
+// An active "block" volume is exported with a dm block device.
+type LogicalVolume struct {
+	VolumeGroup dbus.ObjectPath
+	Name        string
+	Size        uint64
+	Type        string
+	Layout      string
+	Active      bool
+	ThinPool    dbus.ObjectPath
+	Origin      dbus.ObjectPath
+	Props       Props
+}
+
+type unlockFixture struct {
+	passphrase string
+	cleartext  *Block
+}
+
+// DrivePath returns the object path udisksd uses for a drive id
+func DrivePath(id string) dbus.ObjectPath {
+	return dbus.ObjectPath("/org/freedesktop/UDisks2/drives/" + escape(id, false))
+}
+
+// BlockPath returns the object path udisksd uses for a block device name
+func BlockPath(name string) dbus.ObjectPath {
+	return dbus.ObjectPath("/org/freedesktop/UDisks2/block_devices/" + escape(name, true))
+}
+
+// MDRaidPath returns the object path udisksd uses for a RAID array UUID
+func MDRaidPath(uuid string) dbus.ObjectPath {
+	return dbus.ObjectPath("/org/freedesktop/UDisks2/mdraid/" + escape(uuid, false))
+}
+
+// VolumeGroupPath returns the object path udisksd uses for a volume group
+func VolumeGroupPath(name string) dbus.ObjectPath {
+	return dbus.ObjectPath("/org/freedesktop/UDisks2/lvm/" + escape(name, false))
+}
+
+// LogicalVolumePath returns the object path udisksd uses for a logical volume
+func LogicalVolumePath(vg, name string) dbus.ObjectPath {
+	return VolumeGroupPath(vg) + dbus.ObjectPath("/"+escape(name, false))
+}
+
+// escape mirrors how udisksd turns names into object path elements: drive
+// ids replace invalid characters by "_", block names by "_" and the hex code
+func escape(name string, hex bool) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_' && !hex:
+			b.WriteRune(r)
+		case hex && r < 0x80:
+			fmt.Fprintf(&b, "_%02x", r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// AddDrive exports a drive and returns its object path
+func (s *Server) AddDrive(d Drive) dbus.ObjectPath {
+	path := DrivePath(d.Id)
+	ifaces := map[string]Props{
+		"org.freedesktop.UDisks2.Drive": merge(Props{
+			"Vendor":                d.Vendor,
+			"Model":                 d.Model,
+			"Revision":              "",
+			"Serial":                d.Serial,
+			"WWN":                   "",
+			"Id":                    d.Id,
+			"Configuration":         map[string]dbus.Variant{},
+			"Media":                 "",
+			"MediaCompatibility":    []string{},
+			"MediaRemovable":        d.Removable,
+			"MediaAvailable":        true,
+			"MediaChangeDetected":   true,
+			"Size":                  d.Size,
+			"TimeDetected":          uint64(0),
+			"TimeMediaDetected":     uint64(0),
+			"Optical":               false,
+			"OpticalBlank":          false,
+			"OpticalNumTracks":      uint32(0),
+			"OpticalNumAudioTracks": uint32(0),
+			"OpticalNumDataTracks":  uint32(0),
+			"OpticalNumSessions":    uint32(0),
+			"RotationRate":          int32(0),
+			"ConnectionBus":         "",
+			"Seat":                  "seat0",
+			"Removable":             d.Removable,
+			"Ejectable":             d.Ejectable,
+			"SortKey":               "",
+			"CanPowerOff":           d.CanPowerOff,
+			"SiblingId":             "",
+		}, d.Props),
+	}
+	if d.Ata != nil {
+		ifaces["org.freedesktop.UDisks2.Drive.Ata"] = d.Ata
+	}
+	if d.NVMeController != nil {
+		ifaces["org.freedesktop.UDisks2.NVMe.Controller"] = d.NVMeController
+	}
+	s.mu.Lock()
+	s.smart[path] = d.SmartAttributes
+	s.mu.Unlock()
+	s.AddObject(path, ifaces)
+	return path
+}
+
+// AddBlock exports a block device and returns its object path
+func (s *Server) AddBlock(b Block) dbus.ObjectPath {
+	path := BlockPath(b.Name)
+	drive := b.Drive
+	if drive == "" {
+		drive = "/"
+	}
+	ifaces := map[string]Props{
+		"org.freedesktop.UDisks2.Block": merge(Props{
+			"Device":                nulTerminated("/dev/" + b.Name),
+			"PreferredDevice":       nulTerminated("/dev/" + b.Name),
+			"Symlinks":              [][]byte{},
+			"DeviceNumber":          uint64(0),
+			"Id":                    "",
+			"Size":                  b.Size,
+			"ReadOnly":              false,
+			"Drive":                 drive,
+			"MDRaid":                dbus.ObjectPath("/"),
+			"MDRaidMember":          dbus.ObjectPath("/"),
+			"IdUsage":               b.IdUsage,
+			"IdType":                b.IdType,
+			"IdVersion":             "",
+			"IdLabel":               b.IdLabel,
+			"IdUUID":                b.IdUUID,
+			"Configuration":         []ConfigurationItem{},
+			"CryptoBackingDevice":   dbus.ObjectPath("/"),
+			"HintPartitionable":     true,
+			"HintSystem":            false,
+			"HintIgnore":            false,
+			"HintAuto":              false,
+			"HintName":              "",
+			"HintIconName":          "",
+			"HintSymbolicIconName":  "",
+			"UserspaceMountOptions": []string{},
+		}, b.Props),
+	}
+	if b.Filesystem != nil {
+		ifaces["org.freedesktop.UDisks2.Filesystem"] = merge(Props{
+			"MountPoints": [][]byte{},
+			"Size":        b.Size,
+		}, b.Filesystem)
+	}
+	if b.Partition != nil {
+		ifaces["org.freedesktop.UDisks2.Partition"] = b.Partition
+	}
+	if b.PartitionTable != nil {
+		ifaces["org.freedesktop.UDisks2.PartitionTable"] = b.PartitionTable
+	}
+	if b.Encrypted != nil {
+		ifaces["org.freedesktop.UDisks2.Encrypted"] = merge(Props{
+			"HintEncryptionType": "luks2",
+			"MetadataSize":       uint64(16 << 20),
+			"CleartextDevice":    dbus.ObjectPath("/"),
+			"ChildConfiguration": []ConfigurationItem{},
+		}, b.Encrypted)
+		s.mu.Lock()
+		s.unlock[path] = unlockFixture{passphrase: b.Passphrase, cleartext: b.Cleartext}
+		s.mu.Unlock()
+	}
+	if b.Loop != nil {
+		ifaces["org.freedesktop.UDisks2.Loop"] = merge(Props{
+			"BackingFile": []byte{0},
+			"Autoclear":   false,
+			"SetupByUID":  uint32(0),
+		}, b.Loop)
+	}
+	if b.Swapspace != nil {
+		ifaces["org.freedesktop.UDisks2.Swapspace"] = merge(Props{"Active": false}, b.Swapspace)
+	}
+	if b.NVMeNamespace != nil {
+		ifaces["org.freedesktop.UDisks2.NVMe.Namespace"] = merge(Props{
+			"NSID":                   uint32(1),
+			"NGUID":                  "",
+			"EUI64":                  "",
+			"UUID":                   "",
+			"WWN":                    "",
+			"LBAFormats":             []LBAFormat{{512, 0, 0}, {4096, 0, 0}},
+			"FormattedLBASize":       LBAFormat{512, 0, 0},
+			"NamespaceSize":          b.Size / 512,
+			"NamespaceCapacity":      b.Size / 512,
+			"NamespaceUtilization":   uint64(0),
+			"FormatPercentRemaining": int32(-1),
+		}, b.NVMeNamespace)
+	}
+	s.AddObject(path, ifaces)
+	return path
+}
+
+// AddMDRaid exports a RAID array and returns its object path
+func (s *Server) AddMDRaid(m MDRaid) dbus.ObjectPath {
+	path := MDRaidPath(m.UUID)
+	s.AddObject(path, map[string]Props{
+		"org.freedesktop.UDisks2.MDRaid": merge(Props{
+			"UUID":              m.UUID,
+			"Name":              m.Name,
+			"Level":             m.Level,
+			"NumDevices":        uint32(len(m.Members)),
+			"Size":              m.Size,
+			"SyncAction":        "",
+			"SyncCompleted":     float64(0),
+			"SyncRate":          uint64(0),
+			"SyncRemainingTime": uint64(0),
+			"Degraded":          uint32(0),
+			"BitmapLocation":    []byte{0},
+			"ChunkSize":         uint64(512 << 10),
+			"ActiveDevices":     []MDRaidDevice{},
+			"Running":           false,
+		}, m.Props),
+	})
+	for _, b := range m.Members {
+		s.SetProperty(b, "org.freedesktop.UDisks2.Block", "MDRaidMember", path)
+	}
+	if m.Running {
+		s.startMDRaid(path)
+	}
+	return path
+}
+
+// AddVolumeGroup exports a volume group and its physical volumes and returns
+// its object path
+func (s *Server) AddVolumeGroup(vg VolumeGroup) dbus.ObjectPath {
+	path := VolumeGroupPath(vg.Name)
+	s.AddObject(path, map[string]Props{
+		"org.freedesktop.UDisks2.VolumeGroup": merge(Props{
+			"Name":                   vg.Name,
+			"UUID":                   "",
+			"Size":                   uint64(0),
+			"FreeSize":               uint64(0),
+			"ExtentSize":             uint64(4 << 20),
+			"MissingPhysicalVolumes": []string{},
+		}, vg.Props),
+	})
+	for _, b := range vg.Members {
+		s.addPhysicalVolume(path, b)
+	}
+	return path
+}
+
+// AddLogicalVolume exports a logical volume and returns its object path
+func (s *Server) AddLogicalVolume(lv LogicalVolume) dbus.ObjectPath {
+	v, _ := s.Property(lv.VolumeGroup, "org.freedesktop.UDisks2.VolumeGroup", "Name")
+	vgName, _ := v.(string)
+	path := LogicalVolumePath(vgName, lv.Name)
+	if lv.Type == "" {
+		lv.Type = "block"
+	}
+	if lv.Layout == "" {
+		lv.Layout = "linear"
+	}
+	if lv.ThinPool == "" {
+		lv.ThinPool = "/"
+	}
+	if lv.Origin == "" {
+		lv.Origin = "/"
+	}
+	s.AddObject(path, map[string]Props{
+		"org.freedesktop.UDisks2.LogicalVolume": merge(Props{
+			"VolumeGroup":            lv.VolumeGroup,
+			"Name":                   lv.Name,
+			"UUID":                   "",
+			"Size":                   lv.Size,
+			"DataAllocatedRatio":     float64(0),
+			"MetadataAllocatedRatio": float64(0),
+			"Active":                 false,
+			"Type":                   lv.Type,
+			"Layout":                 lv.Layout,
+			"ThinPool":               lv.ThinPool,
+			"Origin":                 lv.Origin,
+			"BlockDevice":            dbus.ObjectPath("/"),
+			"SyncRatio":              float64(1),
+		}, lv.Props),
+	})
+	if usesExtents(lv.Layout) {
+		s.allocate(lv.VolumeGroup, lv.Size)
+	}
+	if lv.Active {
+		s.activateLogicalVolume(path)
+	}
+	return path
+}
+
+// AddJob exports a job object with the given org.freedesktop.UDisks2.Job
+// properties and returns its path. Remove it with RemoveObject, optionally after
+// emitting the Completed signal with CompleteJob.
+func (s *Server) AddJob(props Props) dbus.ObjectPath {
+	s.mu.Lock()
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/UDisks2/jobs/%d", s.nextJob))
+	s.nextJob++
+	s.mu.Unlock()
+	s.AddObject(path, map[string]Props{
+		"org.freedesktop.UDisks2.Job": merge(Props{
+			"Operation":       "",
+			"Progress":        float64(0),
+			"ProgressValid":   false,
+			"Bytes":           uint64(0),
+			"Rate":            uint64(0),
+			"StartTime":       uint64(0),
+			"ExpectedEndTime": uint64(0),
+			"Objects":         []dbus.ObjectPath{},
+			"StartedByUID":    uint32(0),
+			"Cancelable":      true,
+		}, props),
+	})
+	return path
+}
+
+// CompleteJob emits the Completed signal of a job and removes it
+func (s *Server) CompleteJob(path dbus.ObjectPath, success bool, message string) {
+	s.emit(path, "org.freedesktop.UDisks2.Job.Completed", success, message)
+	s.RemoveObject(path)
+}
+
+func (s *Server) addDefaults() {
+	s.AddObject("/org/freedesktop/UDisks2/Manager", map[string]Props{
+		"org.freedesktop.UDisks2.Manager": {
+			"Version":                  "2.10.1",
+			"SupportedFilesystems":     []string{"ext2", "ext3", "ext4", "vfat", "ntfs", "exfat", "xfs", "btrfs", "swap"},
+			"SupportedEncryptionTypes": []string{"luks1", "luks2"},
+			"DefaultEncryptionType":    "luks2",
+		},
+	})
+	s.handlers["org.freedesktop.UDisks2.Manager.GetBlockDevices"] = s.getBlockDevices
+	s.handlers["org.freedesktop.UDisks2.Filesystem.Mount"] = s.mount
+	s.handlers["org.freedesktop.UDisks2.Filesystem.Unmount"] = s.unmount
+	s.handlers["org.freedesktop.UDisks2.Encrypted.Unlock"] = s.unlockDevice
+	s.handlers["org.freedesktop.UDisks2.Encrypted.Lock"] = s.lock
+	s.handlers["org.freedesktop.UDisks2.Drive.PowerOff"] = s.powerOff
+	s.handlers["org.freedesktop.UDisks2.Drive.Eject"] = s.eject
+	s.handlers["org.freedesktop.UDisks2.Manager.LoopSetup"] = s.loopSetup
+	s.handlers["org.freedesktop.UDisks2.Loop.Delete"] = s.loopDelete
+	s.handlers["org.freedesktop.UDisks2.Loop.SetAutoclear"] = s.loopSetAutoclear
+	s.handlers["org.freedesktop.UDisks2.Manager.MDRaidCreate"] = s.mdraidCreate
+	s.handlers["org.freedesktop.UDisks2.MDRaid.Start"] = s.mdraidStart
+	s.handlers["org.freedesktop.UDisks2.MDRaid.Stop"] = s.mdraidStop
+	s.handlers["org.freedesktop.UDisks2.MDRaid.AddDevice"] = s.mdraidAddDevice
+	s.handlers["org.freedesktop.UDisks2.MDRaid.RemoveDevice"] = s.mdraidRemoveDevice
+	s.handlers["org.freedesktop.UDisks2.MDRaid.SetBitmapLocation"] = s.mdraidSetBitmapLocation
+	s.handlers["org.freedesktop.UDisks2.MDRaid.RequestSyncAction"] = s.mdraidRequestSyncAction
+	s.handlers["org.freedesktop.UDisks2.MDRaid.Delete"] = s.mdraidDelete
+	s.handlers["org.freedesktop.UDisks2.VolumeGroup.CreatePlainVolume"] = s.createVolume("block", "linear")
+	s.handlers["org.freedesktop.UDisks2.VolumeGroup.CreateThinPoolVolume"] = s.createVolume("pool", "thin-pool")
+	s.handlers["org.freedesktop.UDisks2.VolumeGroup.CreateThinVolume"] = s.createVolume("block", "thin")
+	s.handlers["org.freedesktop.UDisks2.VolumeGroup.CreateVDOVolume"] = s.createVDOVolume
+	s.handlers["org.freedesktop.UDisks2.VolumeGroup.AddDevice"] = s.volumeGroupAddDevice
+	s.handlers["org.freedesktop.UDisks2.VolumeGroup.RemoveDevice"] = s.volumeGroupRemoveDevice
+	s.handlers["org.freedesktop.UDisks2.VolumeGroup.EmptyDevice"] = s.volumeGroupEmptyDevice
+	s.handlers["org.freedesktop.UDisks2.LogicalVolume.Resize"] = s.resizeLogicalVolume
+	s.handlers["org.freedesktop.UDisks2.LogicalVolume.Rename"] = s.renameLogicalVolume
+	s.handlers["org.freedesktop.UDisks2.LogicalVolume.Activate"] = s.activate
+	s.handlers["org.freedesktop.UDisks2.LogicalVolume.Deactivate"] = s.deactivate
+	s.handlers["org.freedesktop.UDisks2.LogicalVolume.CreateSnapshot"] = s.createSnapshot
+	s.handlers["org.freedesktop.UDisks2.LogicalVolume.Delete"] = s.deleteLogicalVolume
+	s.handlers["org.freedesktop.UDisks2.Manager.CanFormat"] = s.canFormat
+	s.handlers["org.freedesktop.UDisks2.Manager.CanCheck"] = s.canFilesystemOp(0)
+	s.handlers["org.freedesktop.UDisks2.Manager.CanRepair"] = s.canFilesystemOp(1)
+	s.handlers["org.freedesktop.UDisks2.Manager.CanResize"] = s.canResize
+	s.handlers["org.freedesktop.UDisks2.Block.Format"] = s.format
+	s.handlers["org.freedesktop.UDisks2.Filesystem.Check"] = s.checkFilesystem
+	s.handlers["org.freedesktop.UDisks2.Filesystem.Repair"] = s.checkFilesystem
+	s.handlers["org.freedesktop.UDisks2.Filesystem.Resize"] = s.resizeFilesystem
+	s.handlers["org.freedesktop.UDisks2.Filesystem.SetLabel"] = s.setBlockProperty("IdLabel")
+	s.handlers["org.freedesktop.UDisks2.Filesystem.SetUUID"] = s.setBlockProperty("IdUUID")
+	s.handlers["org.freedesktop.UDisks2.Filesystem.TakeOwnership"] = s.takeOwnership
+	s.handlers["org.freedesktop.UDisks2.Swapspace.Start"] = s.setSwapspaceActive(true)
+	s.handlers["org.freedesktop.UDisks2.Swapspace.Stop"] = s.setSwapspaceActive(false)
+	s.handlers["org.freedesktop.UDisks2.Swapspace.SetLabel"] = s.setBlockProperty("IdLabel")
+	s.handlers["org.freedesktop.UDisks2.Swapspace.SetUUID"] = s.setBlockProperty("IdUUID")
+	s.handlers["org.freedesktop.UDisks2.PartitionTable.CreatePartition"] = s.createPartition
+	s.handlers["org.freedesktop.UDisks2.PartitionTable.CreatePartitionAndFormat"] = s.createPartitionAndFormat
+	s.handlers["org.freedesktop.UDisks2.Partition.Delete"] = s.deletePartition
+	s.handlers["org.freedesktop.UDisks2.Partition.Resize"] = s.resizePartition
+	s.handlers["org.freedesktop.UDisks2.Partition.SetType"] = s.setPartitionProperty("Type")
+	s.handlers["org.freedesktop.UDisks2.Partition.SetName"] = s.setPartitionProperty("Name")
+	s.handlers["org.freedesktop.UDisks2.Partition.SetFlags"] = s.setPartitionProperty("Flags")
+	s.handlers["org.freedesktop.UDisks2.Partition.SetUUID"] = s.setPartitionProperty("UUID")
+	s.handlers["org.freedesktop.UDisks2.Job.Cancel"] = s.cancelJob
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.SmartGetAttributes"] = s.smartGetAttributes
+	s.handlers["org.freedesktop.UDisks2.NVMe.Namespace.FormatNamespace"] = s.formatNamespace
+	s.handlers["org.freedesktop.UDisks2.NVMe.Controller.SanitizeStart"] = s.sanitizeStart
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.SecurityEraseUnit"] = s.securityEraseUnit
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.PmGetState"] = s.pmGetState
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.PmStandby"] = s.pmSetStandby(true)
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.PmWakeup"] = s.pmSetStandby(false)
+	s.handlers["org.freedesktop.UDisks2.Drive.SetConfiguration"] = s.setDriveConfiguration
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.SmartUpdate"] = s.smartUpdate("org.freedesktop.UDisks2.Drive.Ata")
+	s.handlers["org.freedesktop.UDisks2.NVMe.Controller.SmartUpdate"] = s.smartUpdate("org.freedesktop.UDisks2.NVMe.Controller")
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.SmartSelftestStart"] = s.selftestStart("org.freedesktop.UDisks2.Drive.Ata")
+	s.handlers["org.freedesktop.UDisks2.Drive.Ata.SmartSelftestAbort"] = s.selftestAbort("org.freedesktop.UDisks2.Drive.Ata")
+	s.handlers["org.freedesktop.UDisks2.NVMe.Controller.SmartSelftestStart"] = s.selftestStart("org.freedesktop.UDisks2.NVMe.Controller")
+	s.handlers["org.freedesktop.UDisks2.NVMe.Controller.SmartSelftestAbort"] = s.selftestAbort("org.freedesktop.UDisks2.NVMe.Controller")
+}
+
+func merge(defaults, props Props) Props {
+	for k, v := range props {
+		defaults[k] = v
+	}
+	return defaults
+}
+
+func nulTerminated(s string) []byte {
+	return append([]byte(s), 0)
+}
@@ -0,0 +1,167 @@
+package udiskstest
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// handler routes incoming method calls to the object tree of the server
+type handler struct {
+	s *Server
+}
+
+type serverObject struct {
+	s    *Server
+	path dbus.ObjectPath
+}
+
+type serverInterface struct {
+	s    *Server
+	path dbus.ObjectPath
+	name string
+}
+
+// method passes the message body undecoded to a HandlerFunc
+type method struct {
+	s      *Server
+	path   dbus.ObjectPath
+	name   string
+	record bool
+	fn     HandlerFunc
+}
+
+func (h *handler) LookupObject(path dbus.ObjectPath) (dbus.ServerObject, bool) {
+	if path == "/org/freedesktop/UDisks2" || h.s.HasObject(path) {
+		return &serverObject{s: h.s, path: path}, true
+	}
+	return nil, false
+}
+
+func (o *serverObject) LookupInterface(name string) (dbus.Interface, bool) {
+	switch name {
+	case "org.freedesktop.DBus.Properties":
+	case "org.freedesktop.DBus.ObjectManager":
+		if o.path != "/org/freedesktop/UDisks2" {
+			return nil, false
+		}
+	default:
+		if !o.s.HasInterface(o.path, name) {
+			return nil, false
+		}
+	}
+	return &serverInterface{s: o.s, path: o.path, name: name}, true
+}
+
+func (i *serverInterface) LookupMethod(name string) (dbus.Method, bool) {
+	m := &method{s: i.s, path: i.path, name: i.name + "." + name}
+	switch m.name {
+	case "org.freedesktop.DBus.Properties.Get":
+		m.fn = i.s.getProperty
+	case "org.freedesktop.DBus.Properties.GetAll":
+		m.fn = i.s.getAllProperties
+	case "org.freedesktop.DBus.Properties.Set":
+		m.fn = i.s.setProperty
+	case "org.freedesktop.DBus.ObjectManager.GetManagedObjects":
+		m.fn = i.s.getManagedObjects
+	default:
+		i.s.mu.Lock()
+		m.fn = i.s.handlers[m.name]
+		i.s.mu.Unlock()
+		m.record = true
+	}
+	return m, m.fn != nil
+}
+
+func (m *method) DecodeArguments(conn *dbus.Conn, sender string, msg *dbus.Message, args []interface{}) ([]interface{}, error) {
+	return args, nil
+}
+
+func (m *method) Call(args ...interface{}) ([]interface{}, error) {
+	if m.record {
+		m.s.mu.Lock()
+		m.s.calls = append(m.s.calls, Call{Path: m.path, Method: m.name, Args: args})
+		m.s.mu.Unlock()
+	}
+	return m.fn(m.path, args)
+}
+
+func (m *method) NumArguments() int {
+	return 0
+}
+
+func (m *method) NumReturns() int {
+	return 0
+}
+
+func (m *method) ArgumentValue(position int) interface{} {
+	return nil
+}
+
+func (m *method) ReturnValue(position int) interface{} {
+	return nil
+}
+
+func (s *Server) getProperty(path dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	iface, name, err := propertyArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	v, ok := s.objects[path][iface][name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, NewError("org.freedesktop.DBus.Error.UnknownProperty", "No such property '"+name+"'")
+	}
+	return []interface{}{v}, nil
+}
+
+func (s *Server) getAllProperties(path dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) != 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	iface, _ := args[0].(string)
+	s.mu.Lock()
+	props := map[string]dbus.Variant{}
+	for k, v := range s.objects[path][iface] {
+		props[k] = v
+	}
+	s.mu.Unlock()
+	return []interface{}{props}, nil
+}
+
+func (s *Server) setProperty(path dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	iface, name, err := propertyArgs(args)
+	if err != nil || len(args) != 3 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	v, ok := args[2].(dbus.Variant)
+	if !ok {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	if _, ok := s.Property(path, iface, name); !ok {
+		return nil, NewError("org.freedesktop.DBus.Error.UnknownProperty", "No such property '"+name+"'")
+	}
+	s.SetProperty(path, iface, name, v)
+	return nil, nil
+}
+
+func (s *Server) getManagedObjects(path dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	s.mu.Lock()
+	objs := make(map[dbus.ObjectPath]map[string]map[string]dbus.Variant, len(s.objects))
+	for p, ifaces := range s.objects {
+		objs[p] = copyInterfaces(ifaces)
+	}
+	s.mu.Unlock()
+	return []interface{}{objs}, nil
+}
+
+func propertyArgs(args []interface{}) (string, string, error) {
+	if len(args) < 2 {
+		return "", "", dbus.ErrMsgInvalidArg
+	}
+	iface, ok1 := args[0].(string)
+	name, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return "", "", dbus.ErrMsgInvalidArg
+	}
+	return iface, name, nil
+}
@@ -0,0 +1,1398 @@
+package udiskstest
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The default implementations below cover the common mount, unlock, power off,
+// partitioning and formatting flows. They can be replaced with Server.Handle.
+
+func (s *Server) getBlockDevices(_ dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	s.mu.Lock()
+	var paths []dbus.ObjectPath
+	for p, ifaces := range s.objects {
+		if _, ok := ifaces["org.freedesktop.UDisks2.Block"]; ok {
+			paths = append(paths, p)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return []interface{}{paths}, nil
+}
+
+func (s *Server) mount(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if mps := s.mountPoints(p); len(mps) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.AlreadyMounted",
+			fmt.Sprintf("Device %s is already mounted at `%s'.", s.deviceName(p), strings.TrimRight(string(mps[0]), "\x00")))
+	}
+	name, _ := s.Property(p, "org.freedesktop.UDisks2.Block", "IdLabel")
+	dir, _ := name.(string)
+	if dir == "" {
+		dir = path.Base(string(p))
+	}
+	mountPoint := "/run/media/udiskstest/" + dir
+	s.SetProperty(p, "org.freedesktop.UDisks2.Filesystem", "MountPoints", [][]byte{nulTerminated(mountPoint)})
+	return []interface{}{mountPoint}, nil
+}
+
+func (s *Server) unmount(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(s.mountPoints(p)) == 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.NotMounted",
+			fmt.Sprintf("Device `%s' is not mounted", s.deviceName(p)))
+	}
+	s.SetProperty(p, "org.freedesktop.UDisks2.Filesystem", "MountPoints", [][]byte{})
+	return nil, nil
+}
+
+func (s *Server) unlockDevice(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if cleartext := s.cleartextDevice(p); cleartext != "/" {
+		return nil, NewError("org.freedesktop.UDisks2.Error.AlreadyUnlocked",
+			fmt.Sprintf("Device %s is already unlocked as %s", s.deviceName(p), s.deviceName(cleartext)))
+	}
+	s.mu.Lock()
+	fixture := s.unlock[p]
+	s.mu.Unlock()
+
+	secret := ""
+	if len(args) > 0 {
+		secret, _ = args[0].(string)
+	}
+	if len(args) > 1 {
+		if opts, ok := args[1].(map[string]dbus.Variant); ok {
+			if v, ok := opts["keyfile_contents"].Value().([]byte); ok {
+				secret = string(v)
+			}
+		}
+	}
+	if secret != fixture.passphrase {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Error unlocking %s: Failed to activate device: Operation not permitted", s.deviceName(p)))
+	}
+
+	s.mu.Lock()
+	name := fmt.Sprintf("dm-%d", s.nextDM)
+	s.nextDM++
+	s.mu.Unlock()
+	cleartext := Block{
+		Name:       name,
+		IdUsage:    "filesystem",
+		IdType:     "ext4",
+		Filesystem: Props{},
+	}
+	if fixture.cleartext != nil {
+		cleartext = *fixture.cleartext
+	}
+	cleartext.Props = merge(Props{"CryptoBackingDevice": p}, cleartext.Props)
+	cleartextPath := s.AddBlock(cleartext)
+	s.SetProperty(p, "org.freedesktop.UDisks2.Encrypted", "CleartextDevice", cleartextPath)
+	return []interface{}{cleartextPath}, nil
+}
+
+func (s *Server) lock(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	cleartext := s.cleartextDevice(p)
+	if cleartext == "/" {
+		return nil, NewError("org.freedesktop.UDisks2.Error.NotUnlocked",
+			fmt.Sprintf("Device %s is not unlocked", s.deviceName(p)))
+	}
+	if len(s.mountPoints(cleartext)) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error locking %s: Device %s is mounted", s.deviceName(p), s.deviceName(cleartext)))
+	}
+	s.RemoveObject(cleartext)
+	s.SetProperty(p, "org.freedesktop.UDisks2.Encrypted", "CleartextDevice", dbus.ObjectPath("/"))
+	return nil, nil
+}
+
+func (s *Server) powerOff(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if v, _ := s.Property(p, "org.freedesktop.UDisks2.Drive", "CanPowerOff"); v != true {
+		return nil, NewError("org.freedesktop.UDisks2.Error.NotSupported", "Drive does not support power off")
+	}
+	blocks := s.blocksOnDrive(p)
+	for _, b := range blocks {
+		if s.inUse(b) {
+			return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+				fmt.Sprintf("Error powering off drive: device %s is in use", s.deviceName(b)))
+		}
+	}
+	for _, b := range blocks {
+		s.RemoveObject(b)
+	}
+	s.RemoveObject(p)
+	return nil, nil
+}
+
+func (s *Server) mountPoints(p dbus.ObjectPath) [][]byte {
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.Filesystem", "MountPoints")
+	mps, _ := v.([][]byte)
+	return mps
+}
+
+// inUse reports whether the block device is mounted, unlocked or active swap
+func (s *Server) inUse(p dbus.ObjectPath) bool {
+	swap, _ := s.Property(p, "org.freedesktop.UDisks2.Swapspace", "Active")
+	return len(s.mountPoints(p)) > 0 || s.cleartextDevice(p) != "/" || swap == true
+}
+
+func (s *Server) cleartextDevice(p dbus.ObjectPath) dbus.ObjectPath {
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.Encrypted", "CleartextDevice")
+	cleartext, ok := v.(dbus.ObjectPath)
+	if !ok {
+		return "/"
+	}
+	return cleartext
+}
+
+func (s *Server) blocksOnDrive(drive dbus.ObjectPath) []dbus.ObjectPath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var blocks []dbus.ObjectPath
+	for p, ifaces := range s.objects {
+		if v, ok := ifaces["org.freedesktop.UDisks2.Block"]["Drive"]; ok && v.Value() == drive {
+			blocks = append(blocks, p)
+		}
+	}
+	return blocks
+}
+
+func (s *Server) deviceName(p dbus.ObjectPath) string {
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.Block", "Device")
+	b, _ := v.([]byte)
+	return strings.TrimRight(string(b), "\x00")
+}
+
+func (s *Server) eject(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if v, _ := s.Property(p, "org.freedesktop.UDisks2.Drive", "Ejectable"); v != true {
+		return nil, NewError("org.freedesktop.UDisks2.Error.NotSupported", "Drive is not ejectable")
+	}
+	blocks := s.blocksOnDrive(p)
+	for _, b := range blocks {
+		if s.inUse(b) {
+			return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+				fmt.Sprintf("Error ejecting media: device %s is in use", s.deviceName(b)))
+		}
+	}
+	for _, b := range blocks {
+		for _, iface := range []string{"org.freedesktop.UDisks2.Filesystem", "org.freedesktop.UDisks2.Encrypted", "org.freedesktop.UDisks2.PartitionTable"} {
+			if s.HasInterface(b, iface) {
+				s.RemoveInterface(b, iface)
+			}
+		}
+		s.SetProperties(b, "org.freedesktop.UDisks2.Block", Props{"Size": uint64(0), "IdUsage": "", "IdType": "", "IdLabel": ""})
+	}
+	s.SetProperties(p, "org.freedesktop.UDisks2.Drive", Props{"MediaAvailable": false, "Media": "", "Size": uint64(0), "Optical": false})
+	return nil, nil
+}
+
+func (s *Server) createPartition(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 4 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	offset, _ := args[0].(uint64)
+	size, _ := args[1].(uint64)
+	partitionType, _ := args[2].(string)
+	name, _ := args[3].(string)
+
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.PartitionTable", "Partitions")
+	partitions, _ := v.([]dbus.ObjectPath)
+	drive, _ := s.Property(p, "org.freedesktop.UDisks2.Block", "Drive")
+	drivePath, _ := drive.(dbus.ObjectPath)
+	number := uint32(len(partitions) + 1)
+	path := s.AddBlock(Block{
+		Name:  fmt.Sprintf("%s%d", strings.TrimPrefix(s.deviceName(p), "/dev/"), number),
+		Drive: drivePath,
+		Size:  size,
+		Partition: Props{
+			"Number":      number,
+			"Type":        partitionType,
+			"Flags":       uint64(0),
+			"Offset":      offset,
+			"Size":        size,
+			"Name":        name,
+			"UUID":        "",
+			"Table":       p,
+			"IsContainer": false,
+			"IsContained": false,
+		},
+	})
+	s.SetProperty(p, "org.freedesktop.UDisks2.PartitionTable", "Partitions", append(partitions, path))
+	return []interface{}{path}, nil
+}
+
+func (s *Server) createPartitionAndFormat(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 7 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	ret, err := s.createPartition(p, args)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.format(ret[0].(dbus.ObjectPath), args[5:]); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *Server) deletePartition(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(s.mountPoints(p)) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error deleting partition %s: Device or resource busy", s.deviceName(p)))
+	}
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.Partition", "Table")
+	table, _ := v.(dbus.ObjectPath)
+	s.RemoveObject(p)
+	v, _ = s.Property(table, "org.freedesktop.UDisks2.PartitionTable", "Partitions")
+	partitions, _ := v.([]dbus.ObjectPath)
+	remaining := []dbus.ObjectPath{}
+	for _, part := range partitions {
+		if part != p {
+			remaining = append(remaining, part)
+		}
+	}
+	s.SetProperty(table, "org.freedesktop.UDisks2.PartitionTable", "Partitions", remaining)
+	return nil, nil
+}
+
+// setPartitionProperty returns a handler storing its first argument in the
+// given property of the Partition interface
+// resizePartition rounds the size up to whole MiB like the alignment of udisksd
+func (s *Server) resizePartition(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	size, _ := args[0].(uint64)
+	size = (size + 1<<20 - 1) &^ (1<<20 - 1)
+	s.SetProperty(p, "org.freedesktop.UDisks2.Partition", "Size", size)
+	s.SetProperty(p, "org.freedesktop.UDisks2.Block", "Size", size)
+	return nil, nil
+}
+
+func (s *Server) setPartitionProperty(name string) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		if len(args) < 1 {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		s.SetProperty(p, "org.freedesktop.UDisks2.Partition", name, args[0])
+		return nil, nil
+	}
+}
+
+func (s *Server) format(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	fsType, _ := args[0].(string)
+	opts, _ := args[1].(map[string]dbus.Variant)
+	if len(s.mountPoints(p)) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error formatting %s: device is mounted", s.deviceName(p)))
+	}
+	label, _ := opts["label"].Value().(string)
+	// udisksd takes the passphrase either as a string or as a byte array
+	passphrase, encrypt := opts["encrypt.passphrase"].Value().(string)
+	if b, ok := opts["encrypt.passphrase"].Value().([]byte); ok {
+		passphrase, encrypt = string(b), true
+	}
+
+	for _, iface := range []string{"org.freedesktop.UDisks2.Filesystem", "org.freedesktop.UDisks2.Encrypted", "org.freedesktop.UDisks2.PartitionTable"} {
+		if s.HasInterface(p, iface) {
+			s.RemoveInterface(p, iface)
+		}
+	}
+	size, _ := s.Property(p, "org.freedesktop.UDisks2.Block", "Size")
+	switch {
+	case fsType == "empty":
+		s.SetProperties(p, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "", "IdType": "", "IdLabel": ""})
+	case fsType == "dos" || fsType == "gpt":
+		s.SetProperties(p, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "", "IdType": "", "IdLabel": ""})
+		s.AddObject(p, map[string]Props{
+			"org.freedesktop.UDisks2.PartitionTable": {"Type": fsType, "Partitions": []dbus.ObjectPath{}},
+		})
+	case encrypt:
+		s.SetProperties(p, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "crypto", "IdType": "crypto_LUKS", "IdLabel": ""})
+		encryptType, _ := opts["encrypt.type"].Value().(string)
+		if encryptType == "" {
+			encryptType = "luks2"
+		}
+		s.mu.Lock()
+		s.unlock[p] = unlockFixture{passphrase: passphrase, cleartext: &Block{
+			Name:       fmt.Sprintf("dm-%d", s.nextDM),
+			IdUsage:    "filesystem",
+			IdType:     fsType,
+			IdLabel:    label,
+			Filesystem: Props{},
+		}}
+		s.nextDM++
+		s.mu.Unlock()
+		s.AddObject(p, map[string]Props{
+			"org.freedesktop.UDisks2.Encrypted": {
+				"HintEncryptionType": encryptType,
+				"MetadataSize":       uint64(16 << 20),
+				"CleartextDevice":    dbus.ObjectPath("/"),
+				"ChildConfiguration": []ConfigurationItem{},
+			},
+		})
+	default:
+		s.SetProperties(p, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "filesystem", "IdType": fsType, "IdLabel": label})
+		s.AddObject(p, map[string]Props{
+			"org.freedesktop.UDisks2.Filesystem": {"MountPoints": [][]byte{}, "Size": size},
+		})
+	}
+	return nil, nil
+}
+
+func (s *Server) canFormat(_ dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	fsType, _ := args[0].(string)
+	if s.supported(fsType) {
+		return []interface{}{canFormatReply{true, ""}}, nil
+	}
+	return []interface{}{canFormatReply{false, "mkfs." + fsType}}, nil
+}
+
+type canFormatReply struct {
+	Available bool
+	Utility   string
+}
+
+// fsTools are the check, repair and resize utilities and the resize mode of the
+// file systems the fake supports
+var fsTools = map[string]struct {
+	tools [3]string
+	mode  uint64
+}{
+	"ext2":  {[3]string{"e2fsck", "e2fsck", "resize2fs"}, 2 | 4 | 16},
+	"ext3":  {[3]string{"e2fsck", "e2fsck", "resize2fs"}, 2 | 4 | 16},
+	"ext4":  {[3]string{"e2fsck", "e2fsck", "resize2fs"}, 2 | 4 | 16},
+	"vfat":  {[3]string{"fsck.vfat", "fsck.vfat", "vfat-resize"}, 2 | 4},
+	"xfs":   {[3]string{"xfs_repair", "xfs_repair", "xfs_growfs"}, 16},
+	"btrfs": {[3]string{"btrfsck", "btrfsck", "btrfs"}, 8 | 16},
+}
+
+// supported reports whether fsType is listed in SupportedFilesystems
+func (s *Server) supported(fsType string) bool {
+	v, _ := s.Property("/org/freedesktop/UDisks2/Manager", "org.freedesktop.UDisks2.Manager", "SupportedFilesystems")
+	supported, _ := v.([]string)
+	for _, t := range supported {
+		if t == fsType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) canFilesystemOp(op int) HandlerFunc {
+	return func(_ dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		if len(args) < 1 {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		fsType, _ := args[0].(string)
+		t, ok := fsTools[fsType]
+		if !ok {
+			return nil, NewError("org.freedesktop.UDisks2.Error.NotSupported", fmt.Sprintf("Filesystem %s is not supported", fsType))
+		}
+		return []interface{}{canFormatReply{s.supported(fsType), t.tools[op]}}, nil
+	}
+}
+
+func (s *Server) canResize(_ dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	fsType, _ := args[0].(string)
+	t, ok := fsTools[fsType]
+	if !ok {
+		return nil, NewError("org.freedesktop.UDisks2.Error.NotSupported", fmt.Sprintf("Filesystem %s is not supported", fsType))
+	}
+	reply := canResizeReply{Available: s.supported(fsType), Mode: t.mode, Utility: t.tools[2]}
+	if !reply.Available {
+		reply.Mode = 0
+	}
+	return []interface{}{reply}, nil
+}
+
+type canResizeReply struct {
+	Available bool
+	Mode      uint64
+	Utility   string
+}
+
+func (s *Server) checkFilesystem(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(s.mountPoints(p)) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error checking %s: device is mounted", s.deviceName(p)))
+	}
+	return []interface{}{true}, nil
+}
+
+func (s *Server) resizeFilesystem(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	size, _ := args[0].(uint64)
+	if size == 0 {
+		v, _ := s.Property(p, "org.freedesktop.UDisks2.Block", "Size")
+		size, _ = v.(uint64)
+	}
+	s.SetProperty(p, "org.freedesktop.UDisks2.Filesystem", "Size", size)
+	return nil, nil
+}
+
+func (s *Server) setBlockProperty(name string) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		if len(args) < 1 {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		s.SetProperty(p, "org.freedesktop.UDisks2.Block", name, args[0])
+		return nil, nil
+	}
+}
+
+func (s *Server) takeOwnership(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(s.mountPoints(p)) == 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.NotMounted",
+			fmt.Sprintf("Device `%s' is not mounted", s.deviceName(p)))
+	}
+	return nil, nil
+}
+
+func (s *Server) cancelJob(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if v, _ := s.Property(p, "org.freedesktop.UDisks2.Job", "Cancelable"); v != true {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "The job cannot be cancelled")
+	}
+	s.CompleteJob(p, false, "Operation was cancelled")
+	return nil, nil
+}
+
+func (s *Server) smartGetAttributes(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if v, _ := s.Property(p, "org.freedesktop.UDisks2.Drive.Ata", "SmartSupported"); v != true {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "SMART is not supported")
+	}
+	s.mu.Lock()
+	attrs := append([]SmartAttribute{}, s.smart[p]...)
+	s.mu.Unlock()
+	for i := range attrs {
+		if attrs[i].Expansion == nil {
+			attrs[i].Expansion = map[string]dbus.Variant{}
+		}
+	}
+	return []interface{}{attrs}, nil
+}
+
+// smartUpdate sets SmartUpdated to the current time
+func (s *Server) smartUpdate(iface string) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		s.mu.Lock()
+		standby := s.standby[p]
+		s.mu.Unlock()
+		if len(args) > 0 && standby {
+			if opts, ok := args[0].(map[string]dbus.Variant); ok && opts["nowakeup"].Value() == true {
+				return nil, NewError("org.freedesktop.UDisks2.Error.WouldWakeup", "Disk is in sleep mode and the nowakeup option was passed")
+			}
+		}
+		s.mu.Lock()
+		s.standby[p] = false
+		s.mu.Unlock()
+		s.SetProperty(p, iface, "SmartUpdated", uint64(time.Now().Unix()))
+		return nil, nil
+	}
+}
+
+// selftestStart marks a self-test as running. Tests complete it by setting
+// SmartSelftestStatus with SetProperties.
+func (s *Server) selftestStart(iface string) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		if v, _ := s.Property(p, iface, "SmartSelftestStatus"); v == "inprogress" {
+			return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "A self-test is already in progress")
+		}
+		s.SetProperties(p, iface, Props{
+			"SmartSelftestStatus":           "inprogress",
+			"SmartSelftestPercentRemaining": int32(100),
+		})
+		return nil, nil
+	}
+}
+
+func (s *Server) selftestAbort(iface string) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		s.SetProperties(p, iface, Props{
+			"SmartSelftestStatus":           "aborted",
+			"SmartSelftestPercentRemaining": int32(-1),
+		})
+		return nil, nil
+	}
+}
+
+func (s *Server) formatNamespace(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	opts, _ := args[0].(map[string]dbus.Variant)
+	if len(s.mountPoints(p)) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error formatting namespace %s: device is mounted", s.deviceName(p)))
+	}
+	var formats []LBAFormat
+	var current LBAFormat
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.NVMe.Namespace", "LBAFormats")
+	dbus.Store([]interface{}{v}, &formats)
+	v, _ = s.Property(p, "org.freedesktop.UDisks2.NVMe.Namespace", "FormattedLBASize")
+	dbus.Store([]interface{}{v}, &current)
+	if size, ok := opts["lba_data_size"].Value().(uint16); ok {
+		found := false
+		for _, f := range formats {
+			if f.DataSize == size {
+				current, found = f, true
+			}
+		}
+		if !found {
+			return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+				fmt.Sprintf("Couldn't match desired LBA data block size in a device supported LBA format data sizes: %d", size))
+		}
+	}
+	total, _ := s.Property(p, "org.freedesktop.UDisks2.Block", "Size")
+	blocks := total.(uint64) / uint64(current.DataSize)
+	s.SetProperties(p, "org.freedesktop.UDisks2.NVMe.Namespace", Props{
+		"FormattedLBASize":     current,
+		"NamespaceSize":        blocks,
+		"NamespaceCapacity":    blocks,
+		"NamespaceUtilization": uint64(0),
+	})
+	s.SetProperties(p, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "", "IdType": "", "IdLabel": ""})
+	return nil, nil
+}
+
+// sanitizeStart runs a sanitize through a job reporting 50% progress, the same
+// way udisksd only replies once the operation is done
+func (s *Server) sanitizeStart(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	action, _ := args[0].(string)
+	opts, _ := args[1].(map[string]dbus.Variant)
+	switch action {
+	case "block-erase", "crypto-erase":
+	case "overwrite":
+		if n, ok := opts["overwrite_pass_count"].Value().(byte); ok && n > 16 {
+			return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "Invalid overwrite pass count")
+		}
+	default:
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", fmt.Sprintf("Unknown sanitize action %s", action))
+	}
+	const iface = "org.freedesktop.UDisks2.NVMe.Controller"
+	job := s.AddJob(Props{"Operation": "nvme-sanitize", "Objects": []dbus.ObjectPath{p}, "Cancelable": false})
+	s.SetProperties(p, iface, Props{"SanitizeStatus": "inprogress", "SanitizePercentRemaining": int32(100)})
+	s.SetProperties(job, "org.freedesktop.UDisks2.Job", Props{"Progress": 0.5, "ProgressValid": true})
+	s.SetProperties(p, iface, Props{"SanitizePercentRemaining": int32(50)})
+	s.SetProperties(p, iface, Props{"SanitizeStatus": "success", "SanitizePercentRemaining": int32(-1)})
+	s.CompleteJob(job, true, "")
+	return nil, nil
+}
+
+func (s *Server) securityEraseUnit(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if v, _ := s.Property(p, "org.freedesktop.UDisks2.Drive.Ata", "SecurityFrozen"); v == true {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "Drive is frozen")
+	}
+	blocks := s.blocksOnDrive(p)
+	for _, b := range blocks {
+		if s.inUse(b) {
+			return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+				fmt.Sprintf("Error erasing drive: device %s is in use", s.deviceName(b)))
+		}
+	}
+	for _, b := range blocks {
+		for _, iface := range []string{"org.freedesktop.UDisks2.Filesystem", "org.freedesktop.UDisks2.Encrypted", "org.freedesktop.UDisks2.PartitionTable", "org.freedesktop.UDisks2.Partition"} {
+			if s.HasInterface(b, iface) {
+				s.RemoveInterface(b, iface)
+			}
+		}
+		s.SetProperties(b, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "", "IdType": "", "IdLabel": "", "IdUUID": ""})
+	}
+	return nil, nil
+}
+
+func (s *Server) pmGetState(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.standby[p] {
+		return []interface{}{byte(0x00)}, nil
+	}
+	return []interface{}{byte(0xff)}, nil
+}
+
+func (s *Server) pmSetStandby(standby bool) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		s.SetStandby(p, standby)
+		return nil, nil
+	}
+}
+
+func (s *Server) setDriveConfiguration(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	values, ok := args[0].(map[string]dbus.Variant)
+	if !ok {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	s.SetProperty(p, "org.freedesktop.UDisks2.Drive", "Configuration", values)
+	return nil, nil
+}
+
+// loopSetup exports a loop device for the passed file descriptor, using the
+// file it refers to as BackingFile
+func (s *Server) loopSetup(_ dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	fd, ok := args[0].(dbus.UnixFD)
+	if !ok {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	f := os.NewFile(uintptr(fd), "")
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", err.Error())
+	}
+	backing, _ := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+	opts, _ := args[1].(map[string]dbus.Variant)
+	offset, _ := opts["offset"].Value().(uint64)
+	size, _ := opts["size"].Value().(uint64)
+	readOnly, _ := opts["read-only"].Value().(bool)
+	if size == 0 && uint64(fi.Size()) > offset {
+		size = uint64(fi.Size()) - offset
+	}
+
+	s.mu.Lock()
+	name := fmt.Sprintf("loop%d", s.nextLoop)
+	s.nextLoop++
+	s.mu.Unlock()
+	p := s.AddBlock(Block{
+		Name:  name,
+		Size:  size,
+		Props: Props{"ReadOnly": readOnly, "HintSystem": false},
+		Loop:  Props{"BackingFile": nulTerminated(backing), "SetupByUID": uint32(os.Getuid())},
+	})
+	return []interface{}{p}, nil
+}
+
+func (s *Server) loopDelete(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(s.mountPoints(p)) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error deleting loop device %s: device is mounted", s.deviceName(p)))
+	}
+	s.RemoveObject(p)
+	return nil, nil
+}
+
+func (s *Server) loopSetAutoclear(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	s.SetProperty(p, "org.freedesktop.UDisks2.Loop", "Autoclear", args[0])
+	return nil, nil
+}
+
+func (s *Server) setSwapspaceActive(active bool) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		if v, _ := s.Property(p, "org.freedesktop.UDisks2.Swapspace", "Active"); v == active {
+			if active {
+				return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "Swapspace is already active")
+			}
+			return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "Swapspace is not active")
+		}
+		s.SetProperty(p, "org.freedesktop.UDisks2.Swapspace", "Active", active)
+		return nil, nil
+	}
+}
+
+// mdraidMinDevices is the number of members needed to create an array of
+// each supported level
+var mdraidMinDevices = map[string]int{
+	"raid0":  2,
+	"raid1":  2,
+	"raid4":  3,
+	"raid5":  3,
+	"raid6":  4,
+	"raid10": 2,
+}
+
+func (s *Server) mdraidCreate(_ dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 4 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	blocks, _ := args[0].([]dbus.ObjectPath)
+	level, _ := args[1].(string)
+	name, _ := args[2].(string)
+	chunk, _ := args[3].(uint64)
+	min, ok := mdraidMinDevices[level]
+	if !ok {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", fmt.Sprintf("Unsupported RAID level %s", level))
+	}
+	if len(blocks) < min {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Error creating RAID array: %s needs at least %d devices", level, min))
+	}
+	var size uint64
+	for _, b := range blocks {
+		if s.inUse(b) {
+			return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+				fmt.Sprintf("Error creating RAID array: device %s is in use", s.deviceName(b)))
+		}
+		v, _ := s.Property(b, "org.freedesktop.UDisks2.Block", "Size")
+		if bs, _ := v.(uint64); size == 0 || bs < size {
+			size = bs
+		}
+	}
+	n := uint64(len(blocks))
+	switch level {
+	case "raid0":
+		size *= n
+	case "raid4", "raid5":
+		size *= n - 1
+	case "raid6":
+		size *= n - 2
+	case "raid10":
+		size = size * n / 2
+	}
+	if level == "raid1" {
+		chunk = 0
+	} else if chunk == 0 {
+		chunk = 512 << 10
+	}
+	for _, b := range blocks {
+		s.SetProperties(b, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "raid", "IdType": "linux_raid_member"})
+	}
+	path := s.AddMDRaid(MDRaid{
+		UUID:    fmt.Sprintf("%08x:%08x:%08x:%08x", rand.Uint32(), rand.Uint32(), rand.Uint32(), rand.Uint32()),
+		Name:    name,
+		Level:   level,
+		Members: blocks,
+		Size:    size,
+		Running: true,
+		Props:   Props{"ChunkSize": chunk},
+	})
+	return []interface{}{path}, nil
+}
+
+// objectsReferencing returns the objects whose property name of iface points
+// at path
+func (s *Server) objectsReferencing(iface, name string, path dbus.ObjectPath) []dbus.ObjectPath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []dbus.ObjectPath
+	for p, ifaces := range s.objects {
+		if v, ok := ifaces[iface][name]; ok && v.Value() == path {
+			paths = append(paths, p)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return paths
+}
+
+func (s *Server) mdraidRunning(p dbus.ObjectPath) bool {
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.MDRaid", "Running")
+	return v == true
+}
+
+func (s *Server) mdraidActiveDevices(p dbus.ObjectPath) []MDRaidDevice {
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.MDRaid", "ActiveDevices")
+	devices, _ := v.([]MDRaidDevice)
+	return devices
+}
+
+// startMDRaid exports the md block device of the array and puts its members
+// into consecutive slots
+func (s *Server) startMDRaid(p dbus.ObjectPath) {
+	members := s.objectsReferencing("org.freedesktop.UDisks2.Block", "MDRaidMember", p)
+	active := make([]MDRaidDevice, 0, len(members))
+	for i, b := range members {
+		active = append(active, MDRaidDevice{Block: b, Slot: int32(i), State: []string{"in_sync"}, Expansion: map[string]dbus.Variant{}})
+	}
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.MDRaid", "NumDevices")
+	num, _ := v.(uint32)
+	degraded := uint32(0)
+	if int(num) > len(members) {
+		degraded = num - uint32(len(members))
+	}
+	v, _ = s.Property(p, "org.freedesktop.UDisks2.MDRaid", "Size")
+	size, _ := v.(uint64)
+
+	s.mu.Lock()
+	name := fmt.Sprintf("md%d", 127-s.nextMD)
+	s.nextMD++
+	s.mu.Unlock()
+	s.AddBlock(Block{Name: name, Size: size, Props: Props{"MDRaid": p}})
+	s.SetProperties(p, "org.freedesktop.UDisks2.MDRaid", Props{
+		"Running":       true,
+		"ActiveDevices": active,
+		"Degraded":      degraded,
+		"SyncAction":    "idle",
+	})
+}
+
+func (s *Server) mdraidStart(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 1 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	if s.mdraidRunning(p) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "RAID array is already running")
+	}
+	opts, _ := args[0].(map[string]dbus.Variant)
+	degraded, _ := opts["start-degraded"].Value().(bool)
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.MDRaid", "NumDevices")
+	if num, _ := v.(uint32); !degraded && len(s.objectsReferencing("org.freedesktop.UDisks2.Block", "MDRaidMember", p)) < int(num) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			"Error starting RAID array: not all members are present, use start-degraded")
+	}
+	s.startMDRaid(p)
+	return nil, nil
+}
+
+func (s *Server) mdraidStop(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if !s.mdraidRunning(p) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "RAID array is not running")
+	}
+	for _, dev := range s.objectsReferencing("org.freedesktop.UDisks2.Block", "MDRaid", p) {
+		if s.inUse(dev) {
+			return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+				fmt.Sprintf("Error stopping RAID array %s: device is in use", s.deviceName(dev)))
+		}
+	}
+	for _, dev := range s.objectsReferencing("org.freedesktop.UDisks2.Block", "MDRaid", p) {
+		s.RemoveObject(dev)
+	}
+	s.SetProperties(p, "org.freedesktop.UDisks2.MDRaid", Props{
+		"Running":       false,
+		"ActiveDevices": []MDRaidDevice{},
+		"Degraded":      uint32(0),
+		"SyncAction":    "",
+	})
+	return nil, nil
+}
+
+func (s *Server) mdraidAddDevice(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	b, _ := args[0].(dbus.ObjectPath)
+	if !s.mdraidRunning(p) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "RAID array is not running")
+	}
+	if s.inUse(b) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error adding %s to RAID array: device is in use", s.deviceName(b)))
+	}
+	active := s.mdraidActiveDevices(p)
+	dev := MDRaidDevice{Block: b, Slot: -1, State: []string{"spare"}, Expansion: map[string]dbus.Variant{}}
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.MDRaid", "Degraded")
+	degraded, _ := v.(uint32)
+	if degraded > 0 {
+		used := map[int32]bool{}
+		for _, a := range active {
+			used[a.Slot] = true
+		}
+		slot := int32(0)
+		for used[slot] {
+			slot++
+		}
+		dev.Slot = slot
+		dev.State = []string{"in_sync"}
+		degraded--
+	}
+	s.SetProperties(b, "org.freedesktop.UDisks2.Block", Props{
+		"MDRaidMember": p,
+		"IdUsage":      "raid",
+		"IdType":       "linux_raid_member",
+	})
+	s.SetProperties(p, "org.freedesktop.UDisks2.MDRaid", Props{
+		"ActiveDevices": append(active, dev),
+		"Degraded":      degraded,
+	})
+	return nil, nil
+}
+
+func (s *Server) mdraidRemoveDevice(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	b, _ := args[0].(dbus.ObjectPath)
+	opts, _ := args[1].(map[string]dbus.Variant)
+	wipe, _ := opts["wipe"].Value().(bool)
+	active := s.mdraidActiveDevices(p)
+	remaining := []MDRaidDevice{}
+	var removed *MDRaidDevice
+	for i := range active {
+		if active[i].Block == b {
+			removed = &active[i]
+		} else {
+			remaining = append(remaining, active[i])
+		}
+	}
+	if removed == nil {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Device %s is not an active member of the RAID array", s.deviceName(b)))
+	}
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.MDRaid", "Degraded")
+	degraded, _ := v.(uint32)
+	if removed.Slot >= 0 {
+		degraded++
+	}
+	s.SetProperties(p, "org.freedesktop.UDisks2.MDRaid", Props{
+		"ActiveDevices": remaining,
+		"Degraded":      degraded,
+	})
+	props := Props{"MDRaidMember": dbus.ObjectPath("/")}
+	if wipe {
+		props["IdUsage"] = ""
+		props["IdType"] = ""
+	}
+	s.SetProperties(b, "org.freedesktop.UDisks2.Block", props)
+	return nil, nil
+}
+
+func (s *Server) mdraidSetBitmapLocation(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	location, _ := args[0].([]byte)
+	if l := strings.TrimRight(string(location), "\x00"); l != "internal" && l != "none" {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			"Only 'none' and 'internal' bitmap locations are supported")
+	}
+	s.SetProperty(p, "org.freedesktop.UDisks2.MDRaid", "BitmapLocation", location)
+	return nil, nil
+}
+
+func (s *Server) mdraidRequestSyncAction(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	action, _ := args[0].(string)
+	if action != "check" && action != "repair" && action != "idle" {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", fmt.Sprintf("Unsupported sync action %s", action))
+	}
+	if !s.mdraidRunning(p) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "RAID array is not running")
+	}
+	s.SetProperties(p, "org.freedesktop.UDisks2.MDRaid", Props{
+		"SyncAction":    action,
+		"SyncCompleted": float64(0),
+	})
+	return nil, nil
+}
+
+func (s *Server) mdraidDelete(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if s.mdraidRunning(p) {
+		if _, err := s.mdraidStop(p, args); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range s.objectsReferencing("org.freedesktop.UDisks2.Block", "MDRaidMember", p) {
+		s.SetProperties(b, "org.freedesktop.UDisks2.Block", Props{
+			"MDRaidMember": dbus.ObjectPath("/"),
+			"IdUsage":      "",
+			"IdType":       "",
+		})
+	}
+	s.RemoveObject(p)
+	return nil, nil
+}
+
+// usesExtents reports whether a volume of the layout takes space from its
+// volume group; thin and VDO volumes live in their pool
+func usesExtents(layout string) bool {
+	return layout != "thin" && layout != "vdo"
+}
+
+func (s *Server) physicalVolumes(vg dbus.ObjectPath) []dbus.ObjectPath {
+	return s.objectsReferencing("org.freedesktop.UDisks2.PhysicalVolume", "VolumeGroup", vg)
+}
+
+func (s *Server) physicalVolumeSize(b dbus.ObjectPath) (size, free uint64) {
+	v, _ := s.Property(b, "org.freedesktop.UDisks2.PhysicalVolume", "Size")
+	size, _ = v.(uint64)
+	v, _ = s.Property(b, "org.freedesktop.UDisks2.PhysicalVolume", "FreeSize")
+	free, _ = v.(uint64)
+	return size, free
+}
+
+func (s *Server) addPhysicalVolume(vg, b dbus.ObjectPath) {
+	v, _ := s.Property(b, "org.freedesktop.UDisks2.Block", "Size")
+	size, _ := v.(uint64)
+	s.AddObject(b, map[string]Props{
+		"org.freedesktop.UDisks2.PhysicalVolume": {
+			"VolumeGroup": vg,
+			"Size":        size,
+			"FreeSize":    size,
+		},
+	})
+	s.SetProperties(b, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "other", "IdType": "LVM2_member"})
+	s.updateVolumeGroupSize(vg)
+}
+
+// updateVolumeGroupSize sums up the sizes of the physical volumes of the group
+func (s *Server) updateVolumeGroupSize(vg dbus.ObjectPath) {
+	var size, free uint64
+	for _, pv := range s.physicalVolumes(vg) {
+		pvSize, pvFree := s.physicalVolumeSize(pv)
+		size += pvSize
+		free += pvFree
+	}
+	s.SetProperties(vg, "org.freedesktop.UDisks2.VolumeGroup", Props{"Size": size, "FreeSize": free})
+}
+
+// allocateFrom takes size bytes from the free space of the physical volumes in
+// order, or fails without changes if they do not have enough
+func (s *Server) allocateFrom(pvs []dbus.ObjectPath, size uint64) error {
+	var free uint64
+	for _, pv := range pvs {
+		_, pvFree := s.physicalVolumeSize(pv)
+		free += pvFree
+	}
+	if size > free {
+		return NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Insufficient free space: %d bytes needed, but only %d available", size, free))
+	}
+	for _, pv := range pvs {
+		_, pvFree := s.physicalVolumeSize(pv)
+		n := pvFree
+		if n > size {
+			n = size
+		}
+		s.SetProperty(pv, "org.freedesktop.UDisks2.PhysicalVolume", "FreeSize", pvFree-n)
+		size -= n
+	}
+	return nil
+}
+
+func (s *Server) allocate(vg dbus.ObjectPath, size uint64) error {
+	if err := s.allocateFrom(s.physicalVolumes(vg), size); err != nil {
+		return err
+	}
+	s.updateVolumeGroupSize(vg)
+	return nil
+}
+
+func (s *Server) release(vg dbus.ObjectPath, size uint64) {
+	for _, pv := range s.physicalVolumes(vg) {
+		pvSize, pvFree := s.physicalVolumeSize(pv)
+		n := pvSize - pvFree
+		if n > size {
+			n = size
+		}
+		s.SetProperty(pv, "org.freedesktop.UDisks2.PhysicalVolume", "FreeSize", pvFree+n)
+		size -= n
+	}
+	s.updateVolumeGroupSize(vg)
+}
+
+func (s *Server) volumeGroupFreeSize(vg dbus.ObjectPath) uint64 {
+	v, _ := s.Property(vg, "org.freedesktop.UDisks2.VolumeGroup", "FreeSize")
+	free, _ := v.(uint64)
+	return free
+}
+
+func (s *Server) logicalVolumeBlock(p dbus.ObjectPath) dbus.ObjectPath {
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "BlockDevice")
+	b, ok := v.(dbus.ObjectPath)
+	if !ok {
+		return "/"
+	}
+	return b
+}
+
+// activateLogicalVolume marks the volume active and exports the block device of
+// a "block" volume
+func (s *Server) activateLogicalVolume(p dbus.ObjectPath) dbus.ObjectPath {
+	if v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Type"); v != "block" {
+		s.SetProperty(p, "org.freedesktop.UDisks2.LogicalVolume", "Active", true)
+		return "/"
+	}
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Size")
+	size, _ := v.(uint64)
+	s.mu.Lock()
+	name := fmt.Sprintf("dm-%d", s.nextDM)
+	s.nextDM++
+	s.mu.Unlock()
+	b := s.AddBlock(Block{Name: name, Size: size, Props: Props{"HintPartitionable": false}})
+	s.AddObject(b, map[string]Props{
+		"org.freedesktop.UDisks2.Block.LVM2": {"LogicalVolume": p},
+	})
+	s.SetProperties(p, "org.freedesktop.UDisks2.LogicalVolume", Props{"Active": true, "BlockDevice": b})
+	return b
+}
+
+// addVolume exports a new active volume after checking its name and the free
+// space of the group
+func (s *Server) addVolume(lv LogicalVolume) ([]interface{}, error) {
+	v, _ := s.Property(lv.VolumeGroup, "org.freedesktop.UDisks2.VolumeGroup", "Name")
+	vgName, _ := v.(string)
+	if s.HasObject(LogicalVolumePath(vgName, lv.Name)) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Logical volume \"%s\" already exists in volume group \"%s\"", lv.Name, vgName))
+	}
+	if free := s.volumeGroupFreeSize(lv.VolumeGroup); usesExtents(lv.Layout) && lv.Size > free {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Insufficient free space: %d bytes needed, but only %d available", lv.Size, free))
+	}
+	lv.Active = true
+	return []interface{}{s.AddLogicalVolume(lv)}, nil
+}
+
+// createVolume returns a handler for the VolumeGroup.Create*Volume methods
+// taking a name and a size, plus the pool for thin volumes
+func (s *Server) createVolume(lvType, layout string) HandlerFunc {
+	return func(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+		if len(args) < 3 {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		lv := LogicalVolume{VolumeGroup: p, Type: lvType, Layout: layout}
+		lv.Name, _ = args[0].(string)
+		lv.Size, _ = args[1].(uint64)
+		if layout == "thin" {
+			if len(args) < 4 {
+				return nil, dbus.ErrMsgInvalidArg
+			}
+			lv.ThinPool, _ = args[2].(dbus.ObjectPath)
+			if v, _ := s.Property(lv.ThinPool, "org.freedesktop.UDisks2.LogicalVolume", "Layout"); v != "thin-pool" {
+				return nil, NewError("org.freedesktop.UDisks2.Error.Failed", fmt.Sprintf("%s is not a thin pool", lv.ThinPool))
+			}
+		}
+		return s.addVolume(lv)
+	}
+}
+
+func (s *Server) createVDOVolume(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 9 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	name, _ := args[0].(string)
+	poolName, _ := args[1].(string)
+	dataSize, _ := args[2].(uint64)
+	virtualSize, _ := args[3].(uint64)
+	policy, _ := args[7].(string)
+	if policy != "auto" && policy != "sync" && policy != "async" {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", fmt.Sprintf("Unknown write policy %s", policy))
+	}
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.VolumeGroup", "Name")
+	vgName, _ := v.(string)
+	if s.HasObject(LogicalVolumePath(vgName, name)) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Logical volume \"%s\" already exists in volume group \"%s\"", name, vgName))
+	}
+	if _, err := s.addVolume(LogicalVolume{VolumeGroup: p, Name: poolName, Size: dataSize, Type: "pool", Layout: "vdo-pool"}); err != nil {
+		return nil, err
+	}
+	return s.addVolume(LogicalVolume{VolumeGroup: p, Name: name, Size: virtualSize, Layout: "vdo"})
+}
+
+func (s *Server) volumeGroupAddDevice(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	b, _ := args[0].(dbus.ObjectPath)
+	if s.HasInterface(b, "org.freedesktop.UDisks2.PhysicalVolume") {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Physical volume %s is already in a volume group", s.deviceName(b)))
+	}
+	if s.inUse(b) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+			fmt.Sprintf("Error adding %s to volume group: device is in use", s.deviceName(b)))
+	}
+	s.addPhysicalVolume(p, b)
+	return nil, nil
+}
+
+// physicalVolumeArg returns the block device argument of a VolumeGroup method
+// if it is a physical volume of the group
+func (s *Server) physicalVolumeArg(p dbus.ObjectPath, args []interface{}) (dbus.ObjectPath, error) {
+	b, _ := args[0].(dbus.ObjectPath)
+	if v, _ := s.Property(b, "org.freedesktop.UDisks2.PhysicalVolume", "VolumeGroup"); v != p {
+		return "", NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("%s is not a physical volume of the volume group", s.deviceName(b)))
+	}
+	return b, nil
+}
+
+func (s *Server) volumeGroupRemoveDevice(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 3 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	b, err := s.physicalVolumeArg(p, args)
+	if err != nil {
+		return nil, err
+	}
+	wipe, _ := args[1].(bool)
+	if size, free := s.physicalVolumeSize(b); free < size {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Physical volume %s is still in use", s.deviceName(b)))
+	}
+	s.RemoveInterface(b, "org.freedesktop.UDisks2.PhysicalVolume")
+	s.updateVolumeGroupSize(p)
+	if wipe {
+		s.SetProperties(b, "org.freedesktop.UDisks2.Block", Props{"IdUsage": "", "IdType": ""})
+	}
+	return nil, nil
+}
+
+func (s *Server) volumeGroupEmptyDevice(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	b, err := s.physicalVolumeArg(p, args)
+	if err != nil {
+		return nil, err
+	}
+	var others []dbus.ObjectPath
+	for _, pv := range s.physicalVolumes(p) {
+		if pv != b {
+			others = append(others, pv)
+		}
+	}
+	size, free := s.physicalVolumeSize(b)
+	if err := s.allocateFrom(others, size-free); err != nil {
+		return nil, err
+	}
+	s.SetProperty(b, "org.freedesktop.UDisks2.PhysicalVolume", "FreeSize", size)
+	return nil, nil
+}
+
+func (s *Server) resizeLogicalVolume(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	size, _ := args[0].(uint64)
+	opts, _ := args[1].(map[string]dbus.Variant)
+	resizeFs, _ := opts["resize_fsys"].Value().(bool)
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Size")
+	cur, _ := v.(uint64)
+	v, _ = s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "VolumeGroup")
+	vg, _ := v.(dbus.ObjectPath)
+	v, _ = s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Layout")
+	if layout, _ := v.(string); usesExtents(layout) {
+		if size > cur {
+			if err := s.allocate(vg, size-cur); err != nil {
+				return nil, err
+			}
+		} else {
+			s.release(vg, cur-size)
+		}
+	}
+	s.SetProperty(p, "org.freedesktop.UDisks2.LogicalVolume", "Size", size)
+	if b := s.logicalVolumeBlock(p); b != "/" {
+		s.SetProperty(b, "org.freedesktop.UDisks2.Block", "Size", size)
+		if resizeFs && s.HasInterface(b, "org.freedesktop.UDisks2.Filesystem") {
+			s.SetProperty(b, "org.freedesktop.UDisks2.Filesystem", "Size", size)
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) renameLogicalVolume(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	name, _ := args[0].(string)
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "VolumeGroup")
+	vg, _ := v.(dbus.ObjectPath)
+	v, _ = s.Property(vg, "org.freedesktop.UDisks2.VolumeGroup", "Name")
+	vgName, _ := v.(string)
+	path := LogicalVolumePath(vgName, name)
+	if s.HasObject(path) {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed",
+			fmt.Sprintf("Logical volume \"%s\" already exists in volume group \"%s\"", name, vgName))
+	}
+	s.mu.Lock()
+	obj := copyInterfaces(s.objects[p])
+	s.mu.Unlock()
+	ifaces := map[string]Props{}
+	for iface, props := range obj {
+		ifaces[iface] = Props{}
+		for k, v := range props {
+			ifaces[iface][k] = v.Value()
+		}
+	}
+	ifaces["org.freedesktop.UDisks2.LogicalVolume"]["Name"] = name
+	s.RemoveObject(p)
+	s.AddObject(path, ifaces)
+	if b := s.logicalVolumeBlock(path); b != "/" {
+		s.SetProperty(b, "org.freedesktop.UDisks2.Block.LVM2", "LogicalVolume", path)
+	}
+	for _, prop := range []string{"ThinPool", "Origin"} {
+		for _, lv := range s.objectsReferencing("org.freedesktop.UDisks2.LogicalVolume", prop, p) {
+			s.SetProperty(lv, "org.freedesktop.UDisks2.LogicalVolume", prop, path)
+		}
+	}
+	return []interface{}{path}, nil
+}
+
+func (s *Server) activate(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Active"); v == true {
+		return []interface{}{s.logicalVolumeBlock(p)}, nil
+	}
+	return []interface{}{s.activateLogicalVolume(p)}, nil
+}
+
+func (s *Server) deactivate(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if b := s.logicalVolumeBlock(p); b != "/" {
+		if s.inUse(b) {
+			return nil, NewError("org.freedesktop.UDisks2.Error.DeviceBusy",
+				fmt.Sprintf("Error deactivating logical volume: device %s is in use", s.deviceName(b)))
+		}
+		s.RemoveObject(b)
+	}
+	s.SetProperties(p, "org.freedesktop.UDisks2.LogicalVolume", Props{"Active": false, "BlockDevice": dbus.ObjectPath("/")})
+	return nil, nil
+}
+
+func (s *Server) createSnapshot(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if len(args) < 3 {
+		return nil, dbus.ErrMsgInvalidArg
+	}
+	lv := LogicalVolume{Origin: p, Layout: "snapshot"}
+	lv.Name, _ = args[0].(string)
+	lv.Size, _ = args[1].(uint64)
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "VolumeGroup")
+	lv.VolumeGroup, _ = v.(dbus.ObjectPath)
+	if lv.Size == 0 {
+		if v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Layout"); v != "thin" {
+			return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "A size is required for snapshots of volumes that are not thin")
+		}
+		v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "ThinPool")
+		lv.ThinPool, _ = v.(dbus.ObjectPath)
+		v, _ = s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Size")
+		lv.Size, _ = v.(uint64)
+		lv.Layout = "thin"
+	}
+	return s.addVolume(lv)
+}
+
+func (s *Server) deleteLogicalVolume(p dbus.ObjectPath, args []interface{}) ([]interface{}, error) {
+	if thin := s.objectsReferencing("org.freedesktop.UDisks2.LogicalVolume", "ThinPool", p); len(thin) > 0 {
+		return nil, NewError("org.freedesktop.UDisks2.Error.Failed", "Thin pool still contains thin volumes")
+	}
+	if _, err := s.deactivate(p, args); err != nil {
+		return nil, err
+	}
+	v, _ := s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "VolumeGroup")
+	vg, _ := v.(dbus.ObjectPath)
+	v, _ = s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Size")
+	size, _ := v.(uint64)
+	v, _ = s.Property(p, "org.freedesktop.UDisks2.LogicalVolume", "Layout")
+	if layout, _ := v.(string); usesExtents(layout) {
+		s.release(vg, size)
+	}
+	s.RemoveObject(p)
+	return nil, nil
+}
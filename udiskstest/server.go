@@ -0,0 +1,371 @@
+// Package udiskstest provides a scriptable fake UDisks2 service running on a
+// private dbus-daemon, so code using the udisks package can be tested without
+// touching the system bus.
+package udiskstest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+)
+
+var ErrNoDaemon = errors.New("dbus-daemon not found in PATH")
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// Props holds the properties of one D-Bus interface. Values are wrapped in
+// variants when they are exported, so they must be of a type D-Bus can encode.
+type Props map[string]interface{}
+
+// HandlerFunc implements a method of the fake service. It receives the object
+// path the method was called on and the decoded arguments. Returning a
+// *dbus.Error (see NewError) sends that error back to the caller.
+type HandlerFunc func(path dbus.ObjectPath, args []interface{}) ([]interface{}, error)
+
+// Call is a method call received by the server
+type Call struct {
+	Path   dbus.ObjectPath
+	Method string
+	Args   []interface{}
+}
+
+// Server is a fake UDisks2 service. Objects are added with AddDrive, AddBlock,
+// AddJob or AddObject and every change is announced with the same signals the
+// real udisksd emits.
+type Server struct {
+	dir     string
+	address string
+	daemon  *exec.Cmd
+	conn    *dbus.Conn
+
+	mu       sync.Mutex
+	objects  map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	handlers map[string]HandlerFunc
+	calls    []Call
+	unlock   map[dbus.ObjectPath]unlockFixture
+	smart    map[dbus.ObjectPath][]SmartAttribute
+	standby  map[dbus.ObjectPath]bool
+	clients  []*dbus.Conn
+	nextJob  int
+	nextDM   int
+	nextLoop int
+	nextMD   int
+}
+
+// NewServer starts a private dbus-daemon and registers the fake service on it
+// as org.freedesktop.UDisks2. Close must be called to stop the daemon.
+func NewServer() (*Server, error) {
+	bin, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		return nil, ErrNoDaemon
+	}
+	dir, err := os.MkdirTemp("", "udiskstest")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		dir:      dir,
+		objects:  map[dbus.ObjectPath]map[string]map[string]dbus.Variant{},
+		handlers: map[string]HandlerFunc{},
+		unlock:   map[dbus.ObjectPath]unlockFixture{},
+		smart:    map[dbus.ObjectPath][]SmartAttribute{},
+		standby:  map[dbus.ObjectPath]bool{},
+	}
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(fmt.Sprintf(busConfig, dir)), 0o600); err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.daemon = exec.Command(bin, "--config-file="+config, "--nofork", "--print-address=1")
+	stdout, err := s.daemon.StdoutPipe()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := s.daemon.Start(); err != nil {
+		s.daemon = nil
+		s.Close()
+		return nil, err
+	}
+	s.address, err = bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.address = strings.TrimSpace(s.address)
+
+	s.conn, err = dbus.Connect(s.address, dbus.WithHandler(&handler{s}))
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := s.requestName(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.addDefaults()
+	return s, nil
+}
+
+// Address returns the address of the private bus
+func (s *Server) Address() string {
+	return s.address
+}
+
+// Conn opens a new connection to the private bus. It is closed together with
+// the server.
+func (s *Server) Conn() (*dbus.Conn, error) {
+	conn, err := dbus.Connect(s.address)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.clients = append(s.clients, conn)
+	s.mu.Unlock()
+	return conn, nil
+}
+
+// Client returns a udisks client connected to the fake service
+func (s *Server) Client() (*udisks.Client, error) {
+	conn, err := s.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return udisks.NewClientWithConn(conn), nil
+}
+
+// Close disconnects all clients and stops the dbus-daemon
+func (s *Server) Close() error {
+	s.mu.Lock()
+	clients := s.clients
+	s.clients = nil
+	s.mu.Unlock()
+	for _, c := range clients {
+		c.Close()
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.daemon != nil {
+		s.daemon.Process.Kill()
+		s.daemon.Wait()
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// Restart drops and reacquires the org.freedesktop.UDisks2 name, which looks
+// like a restart of udisksd to watching clients. The object tree is kept.
+func (s *Server) Restart() error {
+	if _, err := s.conn.ReleaseName("org.freedesktop.UDisks2"); err != nil {
+		return err
+	}
+	return s.requestName()
+}
+
+func (s *Server) requestName() error {
+	reply, err := s.conn.RequestName("org.freedesktop.UDisks2", dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("udiskstest: could not own org.freedesktop.UDisks2 (reply %d)", reply)
+	}
+	return nil
+}
+
+// Handle sets the implementation of iface.method, replacing the default
+// behaviour if there is one. A nil fn removes the method, as on older udisks
+// versions.
+func (s *Server) Handle(iface, method string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[iface+"."+method] = fn
+}
+
+// Calls returns the method calls received so far, excluding property access
+// and GetManagedObjects
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns the received calls of iface.method
+func (s *Server) CallsTo(iface, method string) []Call {
+	var calls []Call
+	for _, c := range s.Calls() {
+		if c.Method == iface+"."+method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// AddObject exports an object with the given interfaces and emits InterfacesAdded.
+// Interfaces already present on the object are replaced.
+func (s *Server) AddObject(path dbus.ObjectPath, ifaces map[string]Props) {
+	added := map[string]map[string]dbus.Variant{}
+	for name, props := range ifaces {
+		added[name] = variants(props)
+	}
+	s.mu.Lock()
+	obj, ok := s.objects[path]
+	if !ok {
+		obj = map[string]map[string]dbus.Variant{}
+		s.objects[path] = obj
+	}
+	for name, props := range added {
+		obj[name] = props
+	}
+	s.mu.Unlock()
+	s.emit("/org/freedesktop/UDisks2", "org.freedesktop.DBus.ObjectManager.InterfacesAdded", path, copyInterfaces(added))
+}
+
+// RemoveObject removes the object and emits InterfacesRemoved
+func (s *Server) RemoveObject(path dbus.ObjectPath) {
+	s.mu.Lock()
+	obj, ok := s.objects[path]
+	delete(s.objects, path)
+	delete(s.unlock, path)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s.emit("/org/freedesktop/UDisks2", "org.freedesktop.DBus.ObjectManager.InterfacesRemoved", path, names)
+}
+
+// RemoveInterface removes a single interface from an object and emits InterfacesRemoved
+func (s *Server) RemoveInterface(path dbus.ObjectPath, iface string) {
+	s.mu.Lock()
+	_, ok := s.objects[path][iface]
+	delete(s.objects[path], iface)
+	s.mu.Unlock()
+	if ok {
+		s.emit("/org/freedesktop/UDisks2", "org.freedesktop.DBus.ObjectManager.InterfacesRemoved", path, []string{iface})
+	}
+}
+
+// SetStandby puts a drive into standby or wakes it up, as reported by
+// PmGetState. SmartUpdate with the nowakeup option fails with WouldWakeup while
+// the drive is in standby.
+func (s *Server) SetStandby(path dbus.ObjectPath, standby bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.standby[path] = standby
+}
+
+// HasObject reports whether an object is exported at path
+func (s *Server) HasObject(path dbus.ObjectPath) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.objects[path]
+	return ok
+}
+
+// HasInterface reports whether the object at path implements iface
+func (s *Server) HasInterface(path dbus.ObjectPath, iface string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.objects[path][iface]
+	return ok
+}
+
+// SetProperty changes a property and emits PropertiesChanged
+func (s *Server) SetProperty(path dbus.ObjectPath, iface, name string, value interface{}) {
+	s.SetProperties(path, iface, Props{name: value})
+}
+
+// SetProperties changes several properties of one interface and emits a single
+// PropertiesChanged signal
+func (s *Server) SetProperties(path dbus.ObjectPath, iface string, props Props) {
+	changed := variants(props)
+	s.mu.Lock()
+	obj, ok := s.objects[path]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	if obj[iface] == nil {
+		obj[iface] = map[string]dbus.Variant{}
+	}
+	for name, v := range changed {
+		obj[iface][name] = v
+	}
+	s.mu.Unlock()
+	s.emit(path, "org.freedesktop.DBus.Properties.PropertiesChanged", iface, changed, []string{})
+}
+
+// Property returns the current value of a property
+func (s *Server) Property(path dbus.ObjectPath, iface, name string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.objects[path][iface][name]
+	if !ok {
+		return nil, false
+	}
+	return v.Value(), true
+}
+
+// Emit sends a signal from the fake service
+func (s *Server) Emit(path dbus.ObjectPath, name string, values ...interface{}) error {
+	return s.conn.Emit(path, name, values...)
+}
+
+func (s *Server) emit(path dbus.ObjectPath, name string, values ...interface{}) {
+	s.conn.Emit(path, name, values...)
+}
+
+// NewError returns a D-Bus error as udisksd would send it, for use in handlers
+func NewError(name, message string) *dbus.Error {
+	return dbus.NewError(name, []interface{}{message})
+}
+
+func variants(props Props) map[string]dbus.Variant {
+	m := make(map[string]dbus.Variant, len(props))
+	for name, v := range props {
+		if variant, ok := v.(dbus.Variant); ok {
+			m[name] = variant
+			continue
+		}
+		m[name] = dbus.MakeVariant(v)
+	}
+	return m
+}
+
+func copyInterfaces(ifaces map[string]map[string]dbus.Variant) map[string]map[string]dbus.Variant {
+	c := make(map[string]map[string]dbus.Variant, len(ifaces))
+	for name, props := range ifaces {
+		p := make(map[string]dbus.Variant, len(props))
+		for k, v := range props {
+			p[k] = v
+		}
+		c[name] = p
+	}
+	return c
+}
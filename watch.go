@@ -0,0 +1,346 @@
+package udisks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type EventType int
+
+const (
+	DriveAdded EventType = iota
+	DriveRemoved
+	DriveChanged
+	BlockAdded
+	BlockRemoved
+	BlockChanged
+	MountPointsChanged
+	Unlocked
+	Locked
+	SmartUpdated
+	// Resynced is sent once udisksd appeared on the bus again, e.g. after a
+	// restart, and the state was fetched again. Objects are reported as
+	// removed when udisksd vanishes and as added before Resynced.
+	Resynced
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DriveAdded:
+		return "DriveAdded"
+	case DriveRemoved:
+		return "DriveRemoved"
+	case DriveChanged:
+		return "DriveChanged"
+	case BlockAdded:
+		return "BlockAdded"
+	case BlockRemoved:
+		return "BlockRemoved"
+	case BlockChanged:
+		return "BlockChanged"
+	case MountPointsChanged:
+		return "MountPointsChanged"
+	case Unlocked:
+		return "Unlocked"
+	case Locked:
+		return "Locked"
+	case SmartUpdated:
+		return "SmartUpdated"
+	case Resynced:
+		return "Resynced"
+	}
+	return "Unknown"
+}
+
+// Event describes a change of a UDisks object. Drive or BlockDevice holds the
+// object after the change, or its last known state when it was removed.
+// Snapshot is the complete state after the change.
+type Event struct {
+	Type        EventType
+	Path        string
+	Drive       *Drive
+	BlockDevice *BlockDevice
+	Snapshot    *Snapshot
+}
+
+type watcher struct {
+	c     *Client
+	ctx   context.Context
+	owner string
+	objs  managedObjects
+	snap  *Snapshot
+}
+
+// Watch subscribes to changes of UDisks objects and delivers them on the returned
+// channel until ctx is cancelled or the connection is closed. When udisksd is
+// restarted the state is fetched again and the differences are reported.
+func (c *Client) Watch(ctx context.Context) (<-chan Event, error) {
+	matches := [][]dbus.MatchOption{
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchInterface("org.freedesktop.DBus.ObjectManager"),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.UDisks2"),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchPathNamespace("/org/freedesktop/UDisks2"),
+		},
+		{
+			dbus.WithMatchSender("org.freedesktop.DBus"),
+			dbus.WithMatchInterface("org.freedesktop.DBus"),
+			dbus.WithMatchMember("NameOwnerChanged"),
+			dbus.WithMatchArg(0, "org.freedesktop.UDisks2"),
+		},
+	}
+	sigs, unsubscribe, err := c.subscribe(ctx, matches)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := c.managedObjects(ctx)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+	w := &watcher{
+		c:    c,
+		ctx:  ctx,
+		objs: objs,
+		snap: buildSnapshot(c, objs),
+	}
+	c.conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, "org.freedesktop.UDisks2").Store(&w.owner)
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-sigs:
+				if !ok {
+					return
+				}
+				for _, ev := range w.handle(sig) {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (w *watcher) handle(sig *dbus.Signal) []Event {
+	if sig.Name == "org.freedesktop.DBus.NameOwnerChanged" {
+		if len(sig.Body) != 3 {
+			return nil
+		}
+		name, _ := sig.Body[0].(string)
+		owner, _ := sig.Body[2].(string)
+		if name != "org.freedesktop.UDisks2" {
+			return nil
+		}
+		return w.resync(owner)
+	}
+	if w.owner != "" && sig.Sender != w.owner {
+		return nil
+	}
+	if !strings.HasPrefix(string(sig.Path), "/org/freedesktop/UDisks2") {
+		return nil
+	}
+	switch sig.Name {
+	case "org.freedesktop.DBus.ObjectManager.InterfacesAdded":
+		return w.interfacesAdded(sig)
+	case "org.freedesktop.DBus.ObjectManager.InterfacesRemoved":
+		return w.interfacesRemoved(sig)
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		return w.propertiesChanged(sig)
+	}
+	return nil
+}
+
+func (w *watcher) interfacesAdded(sig *dbus.Signal) []Event {
+	if len(sig.Body) != 2 {
+		return nil
+	}
+	path, _ := sig.Body[0].(dbus.ObjectPath)
+	added, _ := sig.Body[1].(map[string]map[string]dbus.Variant)
+	if path == "" || len(added) == 0 {
+		return nil
+	}
+	ifaces, existed := w.objs[path]
+	if !existed {
+		ifaces = map[string]map[string]dbus.Variant{}
+		w.objs[path] = ifaces
+	}
+	_, hadDrive := ifaces["org.freedesktop.UDisks2.Drive"]
+	_, hadBlock := ifaces["org.freedesktop.UDisks2.Block"]
+	for name, props := range added {
+		ifaces[name] = props
+	}
+	w.snap = buildSnapshot(w.c, w.objs)
+
+	var events []Event
+	if _, ok := ifaces["org.freedesktop.UDisks2.Drive"]; ok {
+		if hadDrive {
+			events = append(events, w.event(DriveChanged, path, w.snap))
+		} else {
+			events = append(events, w.event(DriveAdded, path, w.snap))
+		}
+	}
+	if _, ok := ifaces["org.freedesktop.UDisks2.Block"]; ok {
+		if hadBlock {
+			events = append(events, w.event(BlockChanged, path, w.snap))
+		} else {
+			events = append(events, w.event(BlockAdded, path, w.snap))
+		}
+	}
+	return events
+}
+
+func (w *watcher) interfacesRemoved(sig *dbus.Signal) []Event {
+	if len(sig.Body) != 2 {
+		return nil
+	}
+	path, _ := sig.Body[0].(dbus.ObjectPath)
+	removed, _ := sig.Body[1].([]string)
+	ifaces, ok := w.objs[path]
+	if !ok {
+		return nil
+	}
+	prev := w.snap
+	for _, name := range removed {
+		delete(ifaces, name)
+	}
+	if len(ifaces) == 0 {
+		delete(w.objs, path)
+	}
+	w.snap = buildSnapshot(w.c, w.objs)
+
+	var events []Event
+	for _, name := range removed {
+		switch name {
+		case "org.freedesktop.UDisks2.Drive":
+			ev := w.event(DriveRemoved, path, prev)
+			ev.Snapshot = w.snap
+			events = append(events, ev)
+		case "org.freedesktop.UDisks2.Block":
+			ev := w.event(BlockRemoved, path, prev)
+			ev.Snapshot = w.snap
+			events = append(events, ev)
+		}
+	}
+	if len(events) > 0 {
+		return events
+	}
+	if _, ok := ifaces["org.freedesktop.UDisks2.Drive"]; ok {
+		events = append(events, w.event(DriveChanged, path, w.snap))
+	}
+	if _, ok := ifaces["org.freedesktop.UDisks2.Block"]; ok {
+		events = append(events, w.event(BlockChanged, path, w.snap))
+	}
+	return events
+}
+
+func (w *watcher) propertiesChanged(sig *dbus.Signal) []Event {
+	if len(sig.Body) != 3 {
+		return nil
+	}
+	path := sig.Path
+	iface, _ := sig.Body[0].(string)
+	changed, _ := sig.Body[1].(map[string]dbus.Variant)
+	invalidated, _ := sig.Body[2].([]string)
+	props, ok := w.objs[path][iface]
+	if !ok {
+		return nil
+	}
+	for name, v := range changed {
+		props[name] = v
+	}
+	for _, name := range invalidated {
+		delete(props, name)
+	}
+	w.snap = buildSnapshot(w.c, w.objs)
+
+	switch iface {
+	case "org.freedesktop.UDisks2.Filesystem":
+		if _, ok := changed["MountPoints"]; ok {
+			return []Event{w.event(MountPointsChanged, path, w.snap)}
+		}
+	case "org.freedesktop.UDisks2.Encrypted":
+		if _, ok := changed["CleartextDevice"]; ok {
+			if objectPathProperty(changed, "CleartextDevice") != "" {
+				return []Event{w.event(Unlocked, path, w.snap)}
+			}
+			return []Event{w.event(Locked, path, w.snap)}
+		}
+	case "org.freedesktop.UDisks2.Drive.Ata", "org.freedesktop.UDisks2.NVMe.Controller":
+		if _, ok := changed["SmartUpdated"]; ok {
+			return []Event{w.event(SmartUpdated, path, w.snap)}
+		}
+	}
+	if _, ok := w.objs[path]["org.freedesktop.UDisks2.Drive"]; ok {
+		return []Event{w.event(DriveChanged, path, w.snap)}
+	}
+	if _, ok := w.objs[path]["org.freedesktop.UDisks2.Block"]; ok {
+		return []Event{w.event(BlockChanged, path, w.snap)}
+	}
+	return nil
+}
+
+// resync replaces the known state after udisksd appeared or vanished on the bus
+func (w *watcher) resync(owner string) []Event {
+	w.owner = owner
+	objs := managedObjects{}
+	if owner != "" {
+		if fetched, err := w.c.managedObjects(w.ctx); err == nil {
+			objs = fetched
+		}
+	}
+	prev := w.snap
+	w.objs = objs
+	w.snap = buildSnapshot(w.c, objs)
+
+	var events []Event
+	for _, d := range prev.Drives {
+		if w.snap.driveByPath(d.Path) == nil {
+			events = append(events, Event{Type: DriveRemoved, Path: d.Path, Drive: d, Snapshot: w.snap})
+		}
+	}
+	for _, b := range prev.BlockDevices {
+		if w.snap.BlockDevices.ByDevice(b.Device) == nil {
+			events = append(events, Event{Type: BlockRemoved, Path: b.Device, BlockDevice: b, Snapshot: w.snap})
+		}
+	}
+	for _, d := range w.snap.Drives {
+		if prev.driveByPath(d.Path) == nil {
+			events = append(events, Event{Type: DriveAdded, Path: d.Path, Drive: d, Snapshot: w.snap})
+		}
+	}
+	for _, b := range w.snap.BlockDevices {
+		if prev.BlockDevices.ByDevice(b.Device) == nil {
+			events = append(events, Event{Type: BlockAdded, Path: b.Device, BlockDevice: b, Snapshot: w.snap})
+		}
+	}
+	if owner == "" {
+		return events
+	}
+	return append(events, Event{Type: Resynced, Snapshot: w.snap})
+}
+
+func (w *watcher) event(t EventType, path dbus.ObjectPath, s *Snapshot) Event {
+	return Event{
+		Type:        t,
+		Path:        string(path),
+		Drive:       s.driveByPath(string(path)),
+		BlockDevice: s.BlockDevices.ByDevice(string(path)),
+		Snapshot:    s,
+	}
+}
@@ -0,0 +1,151 @@
+package udisks_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sandbankdisperser/go-udisks"
+	"github.com/sandbankdisperser/go-udisks/udiskstest"
+)
+
+// watch starts watching the fake service until the test ends
+func watch(t *testing.T, c *udisks.Client) <-chan udisks.Event {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	events, err := c.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// nextEvent skips events until one of type typ for the object at path arrives
+func nextEvent(t *testing.T, events <-chan udisks.Event, typ udisks.EventType, path dbus.ObjectPath) udisks.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("event channel closed while waiting for %v of %s", typ, path)
+			}
+			if ev.Type == typ && ev.Path == string(path) {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %v event for %s", typ, path)
+		}
+	}
+}
+
+func TestWatchAddRemove(t *testing.T) {
+	srv, c := newTestClient(t)
+	events := watch(t, c)
+
+	drv := srv.AddDrive(udiskstest.Drive{Id: "USB-Stick", Vendor: "Vendor"})
+	ev := nextEvent(t, events, udisks.DriveAdded, drv)
+	if ev.Drive == nil || ev.Drive.Vendor != "Vendor" || ev.Snapshot.DriveById("USB-Stick") == nil {
+		t.Errorf("unexpected DriveAdded event %+v", ev)
+	}
+	blk := srv.AddBlock(udiskstest.Block{Name: "sdb", Drive: drv, Size: 1 << 30})
+	ev = nextEvent(t, events, udisks.BlockAdded, blk)
+	if ev.BlockDevice == nil || ev.BlockDevice.Size != 1<<30 || ev.BlockDevice.Drive == nil {
+		t.Errorf("unexpected BlockAdded event %+v", ev)
+	}
+
+	srv.RemoveObject(blk)
+	ev = nextEvent(t, events, udisks.BlockRemoved, blk)
+	if ev.BlockDevice == nil || ev.BlockDevice.Device != string(blk) || ev.Snapshot.BlockDevices.ByDevice(string(blk)) != nil {
+		t.Errorf("unexpected BlockRemoved event %+v", ev)
+	}
+	srv.RemoveObject(drv)
+	ev = nextEvent(t, events, udisks.DriveRemoved, drv)
+	if ev.Drive == nil || ev.Drive.Vendor != "Vendor" || len(ev.Snapshot.Drives) != 0 {
+		t.Errorf("unexpected DriveRemoved event %+v", ev)
+	}
+}
+
+func TestWatchMountPoints(t *testing.T) {
+	srv, c := newTestClient(t)
+	fs := srv.AddBlock(udiskstest.Block{Name: "sdb1", IdUsage: "filesystem", IdType: "ext4", IdLabel: "usb", Filesystem: udiskstest.Props{}})
+	events := watch(t, c)
+
+	if _, err := c.Mount(blockDevice(t, c, fs), udisks.MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ev := nextEvent(t, events, udisks.MountPointsChanged, fs)
+	if ev.BlockDevice == nil || !ev.BlockDevice.IsMounted() {
+		t.Errorf("block device not mounted in MountPointsChanged event %+v", ev.BlockDevice)
+	}
+}
+
+func TestWatchUnlock(t *testing.T) {
+	srv, c := newTestClient(t)
+	crypt := srv.AddBlock(udiskstest.Block{Name: "sdb2", IdUsage: "crypto", Encrypted: udiskstest.Props{}, Passphrase: "secret"})
+	events := watch(t, c)
+
+	cleartext, err := c.Unlock(blockDevice(t, c, crypt), udisks.UnlockOptions{Passphrase: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events, udisks.BlockAdded, dbus.ObjectPath(cleartext.Device))
+	ev := nextEvent(t, events, udisks.Unlocked, crypt)
+	if b := ev.Snapshot.BlockDevices.ByDevice(cleartext.Device); b == nil || b.CryptoBackingDevice == nil {
+		t.Errorf("cleartext device missing from the Unlocked snapshot: %+v", b)
+	}
+	if err := c.LockCryptoDevice(string(crypt)); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events, udisks.Locked, crypt)
+}
+
+func TestWatchResync(t *testing.T) {
+	srv, c := newTestClient(t)
+	drv := srv.AddDrive(udiskstest.Drive{Id: "Disk"})
+	blk := srv.AddBlock(udiskstest.Block{Name: "sda", Drive: drv})
+	events := watch(t, c)
+
+	if err := srv.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	var got []udisks.Event
+	timeout := time.After(5 * time.Second)
+	for len(got) == 0 || got[len(got)-1].Type != udisks.Resynced {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-timeout:
+			t.Fatalf("no Resynced event, got %v", got)
+		}
+	}
+	want := []struct {
+		typ  udisks.EventType
+		path dbus.ObjectPath
+	}{
+		{udisks.DriveRemoved, drv},
+		{udisks.BlockRemoved, blk},
+		{udisks.DriveAdded, drv},
+		{udisks.BlockAdded, blk},
+		{udisks.Resynced, ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events after the restart, want %d: %v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Path != string(w.path) {
+			t.Errorf("event %d is %v of %q, want %v of %q", i, got[i].Type, got[i].Path, w.typ, w.path)
+		}
+	}
+	if s := got[len(got)-1].Snapshot; s == nil || s.DriveById("Disk") == nil {
+		t.Errorf("Resynced snapshot misses the drive: %+v", s)
+	}
+
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event %v after Resynced", ev.Type)
+	case <-time.After(200 * time.Millisecond):
+	}
+}